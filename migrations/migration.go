@@ -0,0 +1,132 @@
+// Package migrations applies the versioned SQL scripts of the database schema.
+// Applied versions are recorded in a flyway_schema_history table, so a database
+// migrated by Flyway can be taken over and the other way round.
+package migrations
+
+import (
+	"bufio"
+	"embed"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//go:embed sql/*.sql
+var scripts embed.FS
+
+// Scripts returns the embedded migration scripts of the repository schema
+func Scripts() fs.FS {
+	sub, err := fs.Sub(scripts, "sql")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// scriptName matches v0001__create_order_table.sql and its undo script u0001__create_order_table.sql
+var scriptName = regexp.MustCompile(`^([vu])(\d+)__(\w+)\.sql$`)
+
+// Migration is a versioned schema change with an optional undo script
+type Migration struct {
+	Version     int
+	Description string
+	Script      string
+	Checksum    int32
+	SQL         string
+
+	UndoScript   string
+	UndoChecksum int32
+	UndoSQL      string
+
+	// versionText is the version as written in the file name, Flyway stores it as is
+	versionText string
+}
+
+// Load reads migrations from the root of fsys ordered by version.
+// Files which do not look like migration scripts are skipped.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "read scripts")
+	}
+
+	byVersion := map[int]*Migration{}
+	undo := map[int]string{}
+	for _, entry := range entries {
+		match := scriptName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse version of %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", entry.Name())
+		}
+
+		if match[1] == "u" {
+			if _, ok := undo[version]; ok {
+				return nil, errors.Errorf("duplicate undo script for version %d", version)
+			}
+			undo[version] = entry.Name()
+			continue
+		}
+
+		if _, ok := byVersion[version]; ok {
+			return nil, errors.Errorf("duplicate script for version %d", version)
+		}
+		byVersion[version] = &Migration{
+			Version:     version,
+			Description: strings.Replace(match[3], "_", " ", -1),
+			Script:      entry.Name(),
+			Checksum:    Checksum(string(content)),
+			SQL:         string(content),
+			versionText: match[2],
+		}
+	}
+
+	for version, script := range undo {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, errors.Errorf("undo script %s has no migration", script)
+		}
+		content, err := fs.ReadFile(fsys, script)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", script)
+		}
+		migration.UndoScript = script
+		migration.UndoChecksum = Checksum(string(content))
+		migration.UndoSQL = string(content)
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Checksum computes a script checksum the way Flyway does: CRC32 of all lines
+// without line terminators, so the result does not depend on LF or CRLF endings
+func Checksum(script string) int32 {
+	hash := crc32.NewIEEE()
+	scanner := bufio.NewScanner(strings.NewReader(strings.TrimPrefix(script, "\ufeff")))
+	scanner.Buffer(nil, len(script)+1)
+	for scanner.Scan() {
+		hash.Write(scanner.Bytes())
+	}
+	return int32(hash.Sum32())
+}
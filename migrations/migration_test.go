@@ -0,0 +1,67 @@
+// +build unit
+
+package migrations
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		migrations, err := Load(fstest.MapFS{
+			"v0002__add_index.sql":    {Data: []byte("CREATE INDEX a_idx ON a (id);")},
+			"v0001__create_table.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+			"u0001__create_table.sql": {Data: []byte("DROP TABLE a;")},
+			"README.md":               {Data: []byte("not a migration")},
+		})
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+
+		require.Equal(t, 1, migrations[0].Version)
+		require.Equal(t, "create table", migrations[0].Description)
+		require.Equal(t, "v0001__create_table.sql", migrations[0].Script)
+		require.Equal(t, "0001", migrations[0].versionText)
+		require.Equal(t, "u0001__create_table.sql", migrations[0].UndoScript)
+		require.Equal(t, "DROP TABLE a;", migrations[0].UndoSQL)
+
+		require.Equal(t, 2, migrations[1].Version)
+		require.Empty(t, migrations[1].UndoScript)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("duplicate version", func(t *testing.T) {
+			_, err := Load(fstest.MapFS{
+				"v1__a.sql":  {Data: []byte("SELECT 1;")},
+				"v01__b.sql": {Data: []byte("SELECT 1;")},
+			})
+			require.Error(t, err)
+		})
+
+		t.Run("undo without migration", func(t *testing.T) {
+			_, err := Load(fstest.MapFS{
+				"u0001__a.sql": {Data: []byte("SELECT 1;")},
+			})
+			require.Error(t, err)
+		})
+	})
+}
+
+func TestChecksum(t *testing.T) {
+	require.Equal(t, Checksum("CREATE TABLE a (id INTEGER);\nDROP TABLE a;"), Checksum("CREATE TABLE a (id INTEGER);\r\nDROP TABLE a;\r\n"))
+	require.Equal(t, Checksum("SELECT 1;"), Checksum("\ufeffSELECT 1;"))
+	require.NotEqual(t, Checksum("SELECT 1;"), Checksum("SELECT 2;"))
+	require.Equal(t, int32(0), Checksum(""))
+}
+
+func TestScripts(t *testing.T) {
+	migrations, err := Load(Scripts())
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		require.Equal(t, i+1, migration.Version, migration.Script)
+		require.NotEmpty(t, migration.UndoScript, "%s has no undo script", migration.Script)
+	}
+}
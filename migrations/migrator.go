@@ -0,0 +1,342 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HistoryTable is the table of applied migrations, it has the layout of Flyway's one
+const HistoryTable = "flyway_schema_history"
+
+// Types of history rows, the same as Flyway writes
+const (
+	typeSQL      = "SQL"
+	typeUndoSQL  = "UNDO_SQL"
+	typeBaseline = "BASELINE"
+)
+
+// Errors returned by Migrator, check them with errors.Is
+var (
+	// ErrChecksumMismatch means an applied script was changed afterwards
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrUnknownVersion means the database has a version no script exists for
+	ErrUnknownVersion = errors.New("unknown applied version")
+	// ErrNoUndo means a migration can not be reverted because it has no undo script
+	ErrNoUndo = errors.New("no undo script")
+)
+
+// lockKey identifies the advisory lock held while migrating
+var lockKey = int64(crc32.ChecksumIEEE([]byte(HistoryTable)))
+
+// State is a state of a migration in a database
+type State string
+
+// States reported by Migrator.Status
+const (
+	StatePending State = "pending"
+	StateApplied State = "applied"
+	StateUndone  State = "undone"
+	StateMissing State = "missing"
+)
+
+// Status describes a migration known either from scripts or from the history table
+type Status struct {
+	Version     int
+	Description string
+	Script      string
+	State       State
+	InstalledOn time.Time
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+func NewMigrator(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// historyRow is a row of the history table
+type historyRow struct {
+	rank        int
+	version     int
+	description string
+	kind        string
+	script      string
+	checksum    sql.NullInt64
+	installedOn time.Time
+}
+
+// history is the current state of the history table
+type history struct {
+	applied  map[int]historyRow
+	undone   map[int]historyRow
+	baseline int
+	lastRank int
+}
+
+// Up applies all pending migrations in version order and returns how many were applied.
+// Each migration runs in its own transaction, the first failure stops the run.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, h *history) error {
+		if err := m.validate(h); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if h.isApplied(migration.Version) {
+				continue
+			}
+			h.lastRank++
+			if err := apply(ctx, conn, h.lastRank, migration, typeSQL, migration.Script, migration.Checksum, migration.SQL); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts up to steps latest applied migrations with their undo scripts
+// and returns how many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn, h *history) error {
+		if err := m.validate(h); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := h.applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.UndoScript == "" {
+				return errors.Wrapf(ErrNoUndo, "version %d", migration.Version)
+			}
+			h.lastRank++
+			if err := apply(ctx, conn, h.lastRank, migration, typeUndoSQL, migration.UndoScript, migration.UndoChecksum, migration.UndoSQL); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Validate checks that applied scripts and undo scripts of reverted
+// versions were not changed and that every applied version has a script
+func (m *Migrator) Validate(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, h *history) error {
+		return m.validate(h)
+	})
+}
+
+// Status lists migrations from scripts followed by applied versions without scripts
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn, h *history) error {
+		known := map[int]bool{}
+		for _, migration := range m.migrations {
+			known[migration.Version] = true
+			status := Status{
+				Version:     migration.Version,
+				Description: migration.Description,
+				Script:      migration.Script,
+				State:       StatePending,
+			}
+			if row, ok := h.applied[migration.Version]; ok {
+				status.State, status.InstalledOn = StateApplied, row.installedOn
+			} else if row, ok := h.undone[migration.Version]; ok {
+				status.State, status.InstalledOn = StateUndone, row.installedOn
+			} else if migration.Version <= h.baseline {
+				status.State = StateApplied
+			}
+			statuses = append(statuses, status)
+		}
+
+		var missing []Status
+		for version, row := range h.applied {
+			if !known[version] {
+				missing = append(missing, Status{
+					Version:     version,
+					Description: row.description,
+					Script:      row.script,
+					State:       StateMissing,
+					InstalledOn: row.installedOn,
+				})
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool {
+			return missing[i].Version < missing[j].Version
+		})
+		statuses = append(statuses, missing...)
+
+		return nil
+	})
+	return statuses, err
+}
+
+func (m *Migrator) validate(h *history) error {
+	known := make(map[int]*Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, row := range h.applied {
+		migration, ok := known[version]
+		if !ok {
+			return errors.Wrapf(ErrUnknownVersion, "version %d", version)
+		}
+		if row.checksum.Valid && int32(row.checksum.Int64) != migration.Checksum {
+			return errors.Wrapf(ErrChecksumMismatch, "version %d: applied %d, script %d", version, row.checksum.Int64, migration.Checksum)
+		}
+	}
+
+	// a reverted version may be gone from scripts, it is pending otherwise
+	for version, row := range h.undone {
+		migration, ok := known[version]
+		if !ok || migration.UndoScript == "" {
+			continue
+		}
+		if row.checksum.Valid && int32(row.checksum.Int64) != migration.UndoChecksum {
+			return errors.Wrapf(ErrChecksumMismatch, "version %d: reverted %d, undo script %d", version, row.checksum.Int64, migration.UndoChecksum)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a single connection holding a session advisory lock,
+// so concurrent migrators wait for each other
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, h *history) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get connection error")
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return errors.Wrap(err, "lock error")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	if _, err := conn.ExecContext(ctx, createHistoryTable); err != nil {
+		return errors.Wrap(err, "create history table error")
+	}
+
+	h, err := readHistory(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, h)
+}
+
+const createHistoryTable = `CREATE TABLE IF NOT EXISTS ` + HistoryTable + ` (
+  installed_rank INTEGER       NOT NULL PRIMARY KEY,
+  version        VARCHAR(50),
+  description    VARCHAR(200)  NOT NULL,
+  type           VARCHAR(20)   NOT NULL,
+  script         VARCHAR(1000) NOT NULL,
+  checksum       INTEGER,
+  installed_by   VARCHAR(100)  NOT NULL,
+  installed_on   TIMESTAMP     NOT NULL DEFAULT now(),
+  execution_time INTEGER       NOT NULL,
+  success        BOOLEAN       NOT NULL
+)`
+
+func readHistory(ctx context.Context, conn *sql.Conn) (*history, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT installed_rank, version, description, type, script, checksum, installed_on, success FROM "+HistoryTable+" ORDER BY installed_rank")
+	if err != nil {
+		return nil, errors.Wrap(err, "query history error")
+	}
+	defer rows.Close()
+
+	h := &history{
+		applied: map[int]historyRow{},
+		undone:  map[int]historyRow{},
+	}
+	for rows.Next() {
+		var (
+			row     historyRow
+			version sql.NullString
+			success bool
+		)
+		if err := rows.Scan(&row.rank, &version, &row.description, &row.kind, &row.script, &row.checksum, &row.installedOn, &success); err != nil {
+			return nil, errors.Wrap(err, "scan history error")
+		}
+		if row.rank > h.lastRank {
+			h.lastRank = row.rank
+		}
+		// rows without version are written by Flyway for schema creation
+		if !success || !version.Valid {
+			continue
+		}
+		if row.version, err = strconv.Atoi(version.String); err != nil {
+			return nil, errors.Wrapf(err, "parse history version %q", version.String)
+		}
+
+		switch row.kind {
+		case typeBaseline:
+			h.baseline = row.version
+		case typeUndoSQL:
+			delete(h.applied, row.version)
+			h.undone[row.version] = row
+		default:
+			delete(h.undone, row.version)
+			h.applied[row.version] = row
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "read history error")
+	}
+
+	return h, nil
+}
+
+func (h *history) isApplied(version int) bool {
+	_, ok := h.applied[version]
+	return ok || version <= h.baseline
+}
+
+// apply runs a script and records it in the history table within one transaction
+func apply(ctx context.Context, conn *sql.Conn, rank int, migration *Migration, kind, script string, checksum int32, query string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin transaction error")
+	}
+
+	start := time.Now()
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "%s error", script)
+	}
+	executionTime := time.Since(start) / time.Millisecond
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO "+HistoryTable+" (installed_rank, version, description, type, script, checksum, installed_by, execution_time, success) VALUES ($1, $2, $3, $4, $5, $6, current_user, $7, TRUE)",
+		rank, migration.versionText, migration.Description, kind, script, checksum, int64(executionTime))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "insert history error")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit error")
+	}
+
+	return nil
+}
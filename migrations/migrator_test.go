@@ -0,0 +1,185 @@
+// +build unit
+
+package migrations
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var historyColumns = []string{"installed_rank", "version", "description", "type", "script", "checksum", "installed_on", "success"}
+
+func testMigrations(t *testing.T) []*Migration {
+	migrations, err := Load(fstest.MapFS{
+		"v0001__create_table.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"u0001__create_table.sql": {Data: []byte("DROP TABLE a;")},
+		"v0002__add_index.sql":    {Data: []byte("CREATE INDEX a_idx ON a (id);")},
+		"u0002__add_index.sql":    {Data: []byte("DROP INDEX a_idx;")},
+	})
+	require.NoError(t, err)
+	return migrations
+}
+
+func expectLock(mock sqlmock.Sqlmock, history *sqlmock.Rows) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS flyway_schema_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM flyway_schema_history ORDER BY installed_rank").WillReturnRows(history)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrator_Up(t *testing.T) {
+	ctx := context.Background()
+	migrations := testMigrations(t)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		expectLock(mock, sqlmock.NewRows(historyColumns).
+			AddRow(1, "0001", "create table", "SQL", "v0001__create_table.sql", migrations[0].Checksum, time.Now(), true))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX a_idx ON a (id);")).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO flyway_schema_history").
+			WithArgs(2, "0002", "add index", "SQL", "v0002__add_index.sql", migrations[1].Checksum, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		count, err := NewMigrator(db, migrations).Up(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("script fails", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			expectLock(mock, sqlmock.NewRows(historyColumns))
+			mock.ExpectBegin()
+			mock.ExpectExec("CREATE TABLE a").WillReturnError(dummyError)
+			mock.ExpectRollback()
+			expectUnlock(mock)
+
+			count, err := NewMigrator(db, migrations).Up(ctx)
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.Equal(t, 0, count)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("applied script was changed", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			expectLock(mock, sqlmock.NewRows(historyColumns).
+				AddRow(1, "0001", "create table", "SQL", "v0001__create_table.sql", 42, time.Now(), true))
+			expectUnlock(mock)
+
+			_, err = NewMigrator(db, migrations).Up(ctx)
+			require.True(t, errors.Is(err, ErrChecksumMismatch))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("undo script of a reverted version was changed", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			expectLock(mock, sqlmock.NewRows(historyColumns).
+				AddRow(1, "0001", "create table", "SQL", "v0001__create_table.sql", migrations[0].Checksum, time.Now(), true).
+				AddRow(2, "0001", "create table", "UNDO_SQL", "u0001__create_table.sql", 42, time.Now(), true))
+			expectUnlock(mock)
+
+			_, err = NewMigrator(db, migrations).Up(ctx)
+			require.True(t, errors.Is(err, ErrChecksumMismatch))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("applied version has no script", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			expectLock(mock, sqlmock.NewRows(historyColumns).
+				AddRow(1, "0007", "unknown", "SQL", "v0007__unknown.sql", 42, time.Now(), true))
+			expectUnlock(mock)
+
+			_, err = NewMigrator(db, migrations).Up(ctx)
+			require.True(t, errors.Is(err, ErrUnknownVersion))
+		})
+	})
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrations := testMigrations(t)
+	expectLock(mock, sqlmock.NewRows(historyColumns).
+		AddRow(1, "0001", "create table", "SQL", "v0001__create_table.sql", migrations[0].Checksum, time.Now(), true).
+		AddRow(2, "0002", "add index", "SQL", "v0002__add_index.sql", migrations[1].Checksum, time.Now(), true))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DROP INDEX a_idx;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO flyway_schema_history").
+		WithArgs(3, "0002", "add index", "UNDO_SQL", "u0002__add_index.sql", migrations[1].UndoChecksum, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	count, err := NewMigrator(db, migrations).Down(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	migrations := testMigrations(t)
+	installedOn := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	expectLock(mock, sqlmock.NewRows(historyColumns).
+		AddRow(1, nil, "<< Flyway Schema Creation >>", "SCHEMA", "\"public\"", nil, installedOn, true).
+		AddRow(2, "0001", "create table", "SQL", "v0001__create_table.sql", migrations[0].Checksum, installedOn, true).
+		AddRow(3, "0002", "add index", "SQL", "v0002__add_index.sql", migrations[1].Checksum, installedOn, true).
+		AddRow(4, "0002", "add index", "UNDO_SQL", "u0002__add_index.sql", migrations[1].UndoChecksum, installedOn, true))
+	expectUnlock(mock)
+
+	statuses, err := NewMigrator(db, migrations).Status(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Status{
+		{Version: 1, Description: "create table", Script: "v0001__create_table.sql", State: StateApplied, InstalledOn: installedOn},
+		{Version: 2, Description: "add index", Script: "v0002__add_index.sql", State: StateUndone, InstalledOn: installedOn},
+	}, statuses)
+}
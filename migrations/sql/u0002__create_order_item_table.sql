@@ -0,0 +1 @@
+DROP TABLE order_items;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE customers;
\ No newline at end of file
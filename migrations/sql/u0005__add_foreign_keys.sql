@@ -0,0 +1,7 @@
+DROP INDEX order_items_product_id_idx;
+DROP INDEX order_items_order_id_idx;
+DROP INDEX orders_customer_id_idx;
+
+ALTER TABLE order_items DROP CONSTRAINT order_items_product_id_fkey;
+ALTER TABLE order_items DROP CONSTRAINT order_items_order_id_fkey;
+ALTER TABLE orders DROP CONSTRAINT orders_customer_id_fkey;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE orders DROP COLUMN status;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+DROP INDEX orders_customer_id_created_at_idx;
+
+DROP INDEX orders_status_created_at_idx;
+DROP INDEX orders_amount_idx;
+DROP INDEX orders_created_at_idx;
+
+ALTER TABLE orders DROP COLUMN created_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE order_items DROP COLUMN deleted_at;
+ALTER TABLE orders DROP COLUMN deleted_at;
\ No newline at end of file
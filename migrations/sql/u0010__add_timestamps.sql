@@ -0,0 +1,3 @@
+ALTER TABLE customers DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE order_items DROP COLUMN created_at, DROP COLUMN updated_at;
+ALTER TABLE orders DROP COLUMN updated_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE customers DROP COLUMN public_id;
+ALTER TABLE orders DROP COLUMN public_id;
\ No newline at end of file
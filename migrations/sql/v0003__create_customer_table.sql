@@ -0,0 +1,5 @@
+CREATE TABLE customers (
+  customer_id   SERIAL PRIMARY KEY NOT NULL,
+  balance       NUMERIC(20,4) CHECK(balance >= 0)    NOT NULL,
+  currency      VARCHAR(3) NOT NULL
+);
\ No newline at end of file
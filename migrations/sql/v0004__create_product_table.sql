@@ -0,0 +1,11 @@
+CREATE TABLE products (
+  product_id    SERIAL PRIMARY KEY NOT NULL,
+  sku           VARCHAR(64)  NOT NULL UNIQUE,
+  name          VARCHAR(255) NOT NULL,
+  description   TEXT         NOT NULL DEFAULT '',
+  price         NUMERIC(20,4) CHECK(price >= 0)      NOT NULL,
+  currency      VARCHAR(3)   NOT NULL,
+  active        BOOLEAN      NOT NULL DEFAULT TRUE,
+  created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE orders
+  ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id)
+  REFERENCES customers (customer_id) ON DELETE RESTRICT;
+
+ALTER TABLE order_items
+  ADD CONSTRAINT order_items_order_id_fkey FOREIGN KEY (order_id)
+  REFERENCES orders (order_id) ON DELETE CASCADE;
+
+ALTER TABLE order_items
+  ADD CONSTRAINT order_items_product_id_fkey FOREIGN KEY (product_id)
+  REFERENCES products (product_id) ON DELETE RESTRICT;
+
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+CREATE INDEX order_items_order_id_idx ON order_items (order_id);
+CREATE INDEX order_items_product_id_idx ON order_items (product_id);
\ No newline at end of file
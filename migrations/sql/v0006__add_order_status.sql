@@ -0,0 +1,3 @@
+ALTER TABLE orders
+  ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'draft'
+  CONSTRAINT orders_status_check CHECK (status IN ('draft', 'placed', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE orders ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX orders_created_at_idx ON orders (created_at, order_id);
+CREATE INDEX orders_amount_idx ON orders (amount, order_id);
+CREATE INDEX orders_status_created_at_idx ON orders (status, created_at, order_id);
+
+-- the composite index serves customer lookups, so the plain one is redundant
+CREATE INDEX orders_customer_id_created_at_idx ON orders (customer_id, created_at, order_id);
+DROP INDEX orders_customer_id_idx;
\ No newline at end of file
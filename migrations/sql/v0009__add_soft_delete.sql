@@ -0,0 +1,2 @@
+ALTER TABLE orders ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE order_items ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- public_id is NULL for rows keyed by the serial ID alone, which is their public ID then
+ALTER TABLE orders ADD COLUMN public_id TEXT;
+CREATE UNIQUE INDEX orders_public_id_idx ON orders ((COALESCE(public_id, order_id::text)));
+
+ALTER TABLE customers ADD COLUMN public_id TEXT;
+CREATE UNIQUE INDEX customers_public_id_idx ON customers ((COALESCE(public_id, customer_id::text)));
\ No newline at end of file
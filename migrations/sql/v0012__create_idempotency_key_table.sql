@@ -0,0 +1,9 @@
+-- order_id is NULL only while the transaction which took the key saves the order
+CREATE TABLE idempotency_keys (
+  key                 TEXT PRIMARY KEY NOT NULL,
+  request_hash        TEXT       NOT NULL,
+  order_id            INTEGER    REFERENCES orders (order_id) ON DELETE CASCADE,
+  created_at          TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
\ No newline at end of file
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MinorUnitsNotApplicable marks currencies without a minor unit, e.g. precious metals
+const MinorUnitsNotApplicable = -1
+
+// ErrUnknownCurrency is returned for a currency missing in the registry
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// CurrencyInfo is a currency metadata, see ISO 4217
+type CurrencyInfo struct {
+	Code        Currency
+	NumericCode int
+	MinorUnits  int
+	Name        string
+	Symbol      string
+	ISO         bool
+}
+
+// ParseCurrency returns a registered currency by its alphabetic or numeric code
+func ParseCurrency(s string) (Currency, error) {
+	code := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := currencies[Currency(code)]; ok {
+		return Currency(code), nil
+	}
+
+	if numeric, err := strconv.Atoi(code); err == nil && numeric > 0 {
+		for currency, info := range currencies {
+			if info.NumericCode == numeric {
+				return currency, nil
+			}
+		}
+	}
+
+	return "", errors.Wrapf(ErrUnknownCurrency, "%q", s)
+}
+
+// Info returns currency metadata
+func (c Currency) Info() (CurrencyInfo, bool) {
+	info, ok := currencies[c]
+	return info, ok
+}
+
+// IsValid reports whether the currency is registered
+func (c Currency) IsValid() bool {
+	_, ok := currencies[c]
+	return ok
+}
+
+// MinorUnits returns a number of decimal places of the currency
+// or MinorUnitsNotApplicable
+func (c Currency) MinorUnits() int {
+	info, ok := currencies[c]
+	if !ok {
+		return MinorUnitsNotApplicable
+	}
+	return info.MinorUnits
+}
+
+// Validate returns ErrUnknownCurrency for an unregistered currency
+func (c Currency) Validate() error {
+	if !c.IsValid() {
+		return errors.Wrapf(ErrUnknownCurrency, "%q", string(c))
+	}
+	return nil
+}
+
+// currencies is a registry of known currencies. Non-ISO codes like BTC have
+// ISO set to false and zero NumericCode.
+var currencies = map[Currency]CurrencyInfo{
+	AED: {AED, 784, 2, "UAE Dirham", "د.إ", true},
+	AFN: {AFN, 971, 2, "Afghani", "؋", true},
+	ALL: {ALL, 8, 2, "Lek", "L", true},
+	AMD: {AMD, 51, 2, "Armenian Dram", "֏", true},
+	ANG: {ANG, 532, 2, "Netherlands Antillean Guilder", "ƒ", true},
+	AOA: {AOA, 973, 2, "Kwanza", "Kz", true},
+	ARS: {ARS, 32, 2, "Argentine Peso", "$", true},
+	AUD: {AUD, 36, 2, "Australian Dollar", "A$", true},
+	AWG: {AWG, 533, 2, "Aruban Florin", "ƒ", true},
+	AZN: {AZN, 944, 2, "Azerbaijan Manat", "₼", true},
+	BAM: {BAM, 977, 2, "Convertible Mark", "KM", true},
+	BBD: {BBD, 52, 2, "Barbados Dollar", "$", true},
+	BDT: {BDT, 50, 2, "Taka", "৳", true},
+	BGN: {BGN, 975, 2, "Bulgarian Lev", "лв", true},
+	BHD: {BHD, 48, 3, "Bahraini Dinar", "BD", true},
+	BIF: {BIF, 108, 0, "Burundi Franc", "FBu", true},
+	BMD: {BMD, 60, 2, "Bermudian Dollar", "$", true},
+	BND: {BND, 96, 2, "Brunei Dollar", "$", true},
+	BOB: {BOB, 68, 2, "Boliviano", "Bs.", true},
+	BOV: {BOV, 984, 2, "Mvdol", "", true},
+	BRL: {BRL, 986, 2, "Brazilian Real", "R$", true},
+	BSD: {BSD, 44, 2, "Bahamian Dollar", "$", true},
+	BTC: {BTC, 0, 8, "Bitcoin", "₿", false},
+	BTN: {BTN, 64, 2, "Ngultrum", "Nu.", true},
+	BWP: {BWP, 72, 2, "Pula", "P", true},
+	BYR: {BYR, 974, 0, "Belarusian Ruble", "Br", true},
+	BZD: {BZD, 84, 2, "Belize Dollar", "BZ$", true},
+	CAD: {CAD, 124, 2, "Canadian Dollar", "CA$", true},
+	CDF: {CDF, 976, 2, "Congolese Franc", "FC", true},
+	CHE: {CHE, 947, 2, "WIR Euro", "", true},
+	CHF: {CHF, 756, 2, "Swiss Franc", "CHF", true},
+	CHW: {CHW, 948, 2, "WIR Franc", "", true},
+	CLF: {CLF, 990, 4, "Unidad de Fomento", "UF", true},
+	CLP: {CLP, 152, 0, "Chilean Peso", "$", true},
+	CNY: {CNY, 156, 2, "Yuan Renminbi", "¥", true},
+	COP: {COP, 170, 2, "Colombian Peso", "$", true},
+	COU: {COU, 970, 2, "Unidad de Valor Real", "", true},
+	CRC: {CRC, 188, 2, "Costa Rican Colon", "₡", true},
+	CUC: {CUC, 931, 2, "Peso Convertible", "CUC$", true},
+	CUP: {CUP, 192, 2, "Cuban Peso", "₱", true},
+	CVE: {CVE, 132, 2, "Cabo Verde Escudo", "Esc", true},
+	CZK: {CZK, 203, 2, "Czech Koruna", "Kč", true},
+	DJF: {DJF, 262, 0, "Djibouti Franc", "Fdj", true},
+	DKK: {DKK, 208, 2, "Danish Krone", "kr", true},
+	DOP: {DOP, 214, 2, "Dominican Peso", "RD$", true},
+	DZD: {DZD, 12, 2, "Algerian Dinar", "DA", true},
+	EGP: {EGP, 818, 2, "Egyptian Pound", "E£", true},
+	ERN: {ERN, 232, 2, "Nakfa", "Nfk", true},
+	ETB: {ETB, 230, 2, "Ethiopian Birr", "Br", true},
+	EUR: {EUR, 978, 2, "Euro", "€", true},
+	FJD: {FJD, 242, 2, "Fiji Dollar", "FJ$", true},
+	FKP: {FKP, 238, 2, "Falkland Islands Pound", "£", true},
+	GBP: {GBP, 826, 2, "Pound Sterling", "£", true},
+	GEL: {GEL, 981, 2, "Lari", "₾", true},
+	GHS: {GHS, 936, 2, "Ghana Cedi", "GH₵", true},
+	GIP: {GIP, 292, 2, "Gibraltar Pound", "£", true},
+	GMD: {GMD, 270, 2, "Dalasi", "D", true},
+	GNF: {GNF, 324, 0, "Guinean Franc", "FG", true},
+	GTQ: {GTQ, 320, 2, "Quetzal", "Q", true},
+	GYD: {GYD, 328, 2, "Guyana Dollar", "GY$", true},
+	HKD: {HKD, 344, 2, "Hong Kong Dollar", "HK$", true},
+	HNL: {HNL, 340, 2, "Lempira", "L", true},
+	HRK: {HRK, 191, 2, "Kuna", "kn", true},
+	HTG: {HTG, 332, 2, "Gourde", "G", true},
+	HUF: {HUF, 348, 2, "Forint", "Ft", true},
+	IDR: {IDR, 360, 2, "Rupiah", "Rp", true},
+	ILS: {ILS, 376, 2, "New Israeli Sheqel", "₪", true},
+	INR: {INR, 356, 2, "Indian Rupee", "₹", true},
+	IQD: {IQD, 368, 3, "Iraqi Dinar", "ID", true},
+	IRR: {IRR, 364, 2, "Iranian Rial", "﷼", true},
+	ISK: {ISK, 352, 0, "Iceland Krona", "kr", true},
+	JMD: {JMD, 388, 2, "Jamaican Dollar", "J$", true},
+	JOD: {JOD, 400, 3, "Jordanian Dinar", "JD", true},
+	JPY: {JPY, 392, 0, "Yen", "¥", true},
+	KES: {KES, 404, 2, "Kenyan Shilling", "KSh", true},
+	KGS: {KGS, 417, 2, "Som", "сом", true},
+	KHR: {KHR, 116, 2, "Riel", "៛", true},
+	KMF: {KMF, 174, 0, "Comorian Franc", "CF", true},
+	KPW: {KPW, 408, 2, "North Korean Won", "₩", true},
+	KRW: {KRW, 410, 0, "Won", "₩", true},
+	KWD: {KWD, 414, 3, "Kuwaiti Dinar", "KD", true},
+	KYD: {KYD, 136, 2, "Cayman Islands Dollar", "CI$", true},
+	KZT: {KZT, 398, 2, "Tenge", "₸", true},
+	LAK: {LAK, 418, 2, "Lao Kip", "₭", true},
+	LBP: {LBP, 422, 2, "Lebanese Pound", "LL", true},
+	LKR: {LKR, 144, 2, "Sri Lanka Rupee", "Rs", true},
+	LRD: {LRD, 430, 2, "Liberian Dollar", "L$", true},
+	LSL: {LSL, 426, 2, "Loti", "L", true},
+	LTC: {LTC, 0, 8, "Litecoin", "Ł", false},
+	LTL: {LTL, 440, 2, "Lithuanian Litas", "Lt", true},
+	LVL: {LVL, 428, 2, "Latvian Lats", "Ls", true},
+	LYD: {LYD, 434, 3, "Libyan Dinar", "LD", true},
+	MAD: {MAD, 504, 2, "Moroccan Dirham", "MAD", true},
+	MDL: {MDL, 498, 2, "Moldovan Leu", "L", true},
+	MGA: {MGA, 969, 2, "Malagasy Ariary", "Ar", true},
+	MKD: {MKD, 807, 2, "Denar", "ден", true},
+	MMK: {MMK, 104, 2, "Kyat", "K", true},
+	MNT: {MNT, 496, 2, "Tugrik", "₮", true},
+	MOP: {MOP, 446, 2, "Pataca", "MOP$", true},
+	MRO: {MRO, 478, 2, "Ouguiya", "UM", true},
+	MUR: {MUR, 480, 2, "Mauritius Rupee", "₨", true},
+	MVR: {MVR, 462, 2, "Rufiyaa", "Rf", true},
+	MWK: {MWK, 454, 2, "Malawi Kwacha", "MK", true},
+	MXN: {MXN, 484, 2, "Mexican Peso", "$", true},
+	MXV: {MXV, 979, 2, "Mexican Unidad de Inversion (UDI)", "", true},
+	MYR: {MYR, 458, 2, "Malaysian Ringgit", "RM", true},
+	MZN: {MZN, 943, 2, "Mozambique Metical", "MT", true},
+	NAD: {NAD, 516, 2, "Namibia Dollar", "N$", true},
+	NGN: {NGN, 566, 2, "Naira", "₦", true},
+	NIO: {NIO, 558, 2, "Cordoba Oro", "C$", true},
+	NOK: {NOK, 578, 2, "Norwegian Krone", "kr", true},
+	NPR: {NPR, 524, 2, "Nepalese Rupee", "Rs", true},
+	NZD: {NZD, 554, 2, "New Zealand Dollar", "NZ$", true},
+	OMR: {OMR, 512, 3, "Rial Omani", "RO", true},
+	PAB: {PAB, 590, 2, "Balboa", "B/.", true},
+	PEN: {PEN, 604, 2, "Sol", "S/", true},
+	PGK: {PGK, 598, 2, "Kina", "K", true},
+	PHP: {PHP, 608, 2, "Philippine Peso", "₱", true},
+	PKR: {PKR, 586, 2, "Pakistan Rupee", "Rs", true},
+	PLN: {PLN, 985, 2, "Zloty", "zł", true},
+	PYG: {PYG, 600, 0, "Guarani", "₲", true},
+	QAR: {QAR, 634, 2, "Qatari Rial", "QR", true},
+	RON: {RON, 946, 2, "Romanian Leu", "lei", true},
+	RSD: {RSD, 941, 2, "Serbian Dinar", "дин.", true},
+	RUB: {RUB, 643, 2, "Russian Ruble", "₽", true},
+	RWF: {RWF, 646, 0, "Rwanda Franc", "FRw", true},
+	SAR: {SAR, 682, 2, "Saudi Riyal", "SR", true},
+	SBD: {SBD, 90, 2, "Solomon Islands Dollar", "SI$", true},
+	SCR: {SCR, 690, 2, "Seychelles Rupee", "₨", true},
+	SDG: {SDG, 938, 2, "Sudanese Pound", "LS", true},
+	SEK: {SEK, 752, 2, "Swedish Krona", "kr", true},
+	SGD: {SGD, 702, 2, "Singapore Dollar", "S$", true},
+	SHP: {SHP, 654, 2, "Saint Helena Pound", "£", true},
+	SLL: {SLL, 694, 2, "Leone", "Le", true},
+	SOS: {SOS, 706, 2, "Somali Shilling", "Sh", true},
+	SRD: {SRD, 968, 2, "Surinam Dollar", "$", true},
+	SSP: {SSP, 728, 2, "South Sudanese Pound", "£", true},
+	STD: {STD, 678, 2, "Dobra", "Db", true},
+	SVC: {SVC, 222, 2, "El Salvador Colon", "₡", true},
+	SYP: {SYP, 760, 2, "Syrian Pound", "£S", true},
+	SZL: {SZL, 748, 2, "Lilangeni", "E", true},
+	THB: {THB, 764, 2, "Baht", "฿", true},
+	TJS: {TJS, 972, 2, "Somoni", "SM", true},
+	TMT: {TMT, 934, 2, "Turkmenistan New Manat", "m", true},
+	TND: {TND, 788, 3, "Tunisian Dinar", "DT", true},
+	TOP: {TOP, 776, 2, "Pa’anga", "T$", true},
+	TRY: {TRY, 949, 2, "Turkish Lira", "₺", true},
+	TTD: {TTD, 780, 2, "Trinidad and Tobago Dollar", "TT$", true},
+	TWD: {TWD, 901, 2, "New Taiwan Dollar", "NT$", true},
+	TZS: {TZS, 834, 2, "Tanzanian Shilling", "TSh", true},
+	UAH: {UAH, 980, 2, "Hryvnia", "₴", true},
+	UGX: {UGX, 800, 0, "Uganda Shilling", "USh", true},
+	USD: {USD, 840, 2, "US Dollar", "$", true},
+	USN: {USN, 997, 2, "US Dollar (Next day)", "$", true},
+	USS: {USS, 998, 2, "US Dollar (Same day)", "$", true},
+	UYI: {UYI, 940, 0, "Uruguay Peso en Unidades Indexadas (UI)", "", true},
+	UYU: {UYU, 858, 2, "Peso Uruguayo", "$U", true},
+	UZS: {UZS, 860, 2, "Uzbekistan Sum", "soʻm", true},
+	VEF: {VEF, 937, 2, "Bolivar", "Bs.F", true},
+	VND: {VND, 704, 0, "Dong", "₫", true},
+	VUV: {VUV, 548, 0, "Vatu", "VT", true},
+	WST: {WST, 882, 2, "Tala", "WS$", true},
+	XAF: {XAF, 950, 0, "CFA Franc BEAC", "FCFA", true},
+	XAG: {XAG, 961, MinorUnitsNotApplicable, "Silver", "", true},
+	XAU: {XAU, 959, MinorUnitsNotApplicable, "Gold", "", true},
+	XBA: {XBA, 955, MinorUnitsNotApplicable, "Bond Markets Unit European Composite Unit (EURCO)", "", true},
+	XBB: {XBB, 956, MinorUnitsNotApplicable, "Bond Markets Unit European Monetary Unit (E.M.U.-6)", "", true},
+	XBC: {XBC, 957, MinorUnitsNotApplicable, "Bond Markets Unit European Unit of Account 9 (E.U.A.-9)", "", true},
+	XBD: {XBD, 958, MinorUnitsNotApplicable, "Bond Markets Unit European Unit of Account 17 (E.U.A.-17)", "", true},
+	XCD: {XCD, 951, 2, "East Caribbean Dollar", "EC$", true},
+	XDR: {XDR, 960, MinorUnitsNotApplicable, "SDR (Special Drawing Right)", "", true},
+	XFU: {XFU, 0, MinorUnitsNotApplicable, "UIC-Franc", "", false},
+	XOF: {XOF, 952, 0, "CFA Franc BCEAO", "CFA", true},
+	XPD: {XPD, 964, MinorUnitsNotApplicable, "Palladium", "", true},
+	XPF: {XPF, 953, 0, "CFP Franc", "₣", true},
+	XPT: {XPT, 962, MinorUnitsNotApplicable, "Platinum", "", true},
+	XSU: {XSU, 994, MinorUnitsNotApplicable, "Sucre", "", true},
+	XTS: {XTS, 963, MinorUnitsNotApplicable, "Codes specifically reserved for testing purposes", "", true},
+	XUA: {XUA, 965, MinorUnitsNotApplicable, "ADB Unit of Account", "", true},
+	XXX: {XXX, 999, MinorUnitsNotApplicable, "The codes assigned for transactions where no currency is involved", "", true},
+	YER: {YER, 886, 2, "Yemeni Rial", "﷼", true},
+	ZAR: {ZAR, 710, 2, "Rand", "R", true},
+	ZMK: {ZMK, 894, 2, "Zambian Kwacha", "ZK", true},
+	ZWL: {ZWL, 932, 2, "Zimbabwe Dollar", "Z$", true},
+}
@@ -0,0 +1,81 @@
+// +build unit
+
+package models
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCurrency_Info(t *testing.T) {
+	for currency, expected := range map[Currency]struct {
+		minorUnits  int
+		numericCode int
+		iso         bool
+	}{
+		USD: {2, 840, true},
+		JPY: {0, 392, true},
+		KWD: {3, 414, true},
+		CLF: {4, 990, true},
+		XAU: {MinorUnitsNotApplicable, 959, true},
+		BTC: {8, 0, false},
+		LTC: {8, 0, false},
+	} {
+		info, ok := currency.Info()
+		require.True(t, ok, currency)
+		require.Equal(t, currency, info.Code)
+		require.Equal(t, expected.minorUnits, currency.MinorUnits(), currency)
+		require.Equal(t, expected.numericCode, info.NumericCode, currency)
+		require.Equal(t, expected.iso, info.ISO, currency)
+		require.NotEmpty(t, info.Name)
+	}
+
+	_, ok := Currency("zzz").Info()
+	require.False(t, ok)
+	require.Equal(t, MinorUnitsNotApplicable, Currency("zzz").MinorUnits())
+}
+
+func TestParseCurrency(t *testing.T) {
+	for input, expected := range map[string]Currency{
+		"usd":   USD,
+		" EUR ": EUR,
+		"392":   JPY,
+		"008":   ALL,
+		"btc":   BTC,
+	} {
+		currency, err := ParseCurrency(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, currency)
+		require.True(t, currency.IsValid())
+	}
+
+	for _, input := range []string{"", "zzz", "0", "1000"} {
+		_, err := ParseCurrency(input)
+		require.Equal(t, ErrUnknownCurrency, errors.Cause(err), input)
+	}
+}
+
+func TestMoney_Round(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		currency Currency
+		expected string
+	}{
+		{"10.0050", USD, "10.0100"},
+		{"10.0049", USD, "10.0000"},
+		{"-10.0050", USD, "-10.0100"},
+		{"99.5000", JPY, "100.0000"},
+		{"1.2345", KWD, "1.2350"},
+		{"1.2345", CLF, "1.2345"},
+		{"1.2345", BTC, "1.2345"},
+		{"1.2345", XAU, "1.2345"},
+	} {
+		rounded, err := NewMoney(MustParseDecimal(tc.value), tc.currency).Round()
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, rounded.Value.String(), "%s %s", tc.value, tc.currency)
+	}
+
+	_, err := NewMoney(MustDecimalFromInt(1), "zzz").Round()
+	require.Equal(t, ErrUnknownCurrency, errors.Cause(err))
+}
@@ -1,10 +1,18 @@
 package models
 
+import (
+	"time"
+)
+
 // CustomerID is value object
 type CustomerID int
 
 // Customer is an entity
 type Customer struct {
-	ID      CustomerID
-	Balance Money
+	ID CustomerID
+	// PublicID identifies the customer outside, e.g. in URLs
+	PublicID  string
+	Balance   Money
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
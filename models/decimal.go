@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql/driver"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecimalScale is a number of fractional digits kept by Decimal,
+// it matches NUMERIC(20,4) columns of the database
+const DecimalScale = 4
+
+const decimalFactor = 10000
+
+// ErrDecimalOverflow is returned when a result does not fit into Decimal
+var ErrDecimalOverflow = errors.New("decimal overflow")
+
+// Decimal is an exact fixed-point number counted in 10^-DecimalScale units.
+// Values beyond ±922337203685477.5807 are rejected with ErrDecimalOverflow.
+type Decimal int64
+
+// DecimalFromInt converts a whole number to Decimal
+func DecimalFromInt(i int64) (Decimal, error) {
+	d, err := Decimal(decimalFactor).Mul(i)
+	if err != nil {
+		return 0, errors.Wrapf(err, "convert %d", i)
+	}
+	return d, nil
+}
+
+// MustDecimalFromInt is like DecimalFromInt but panics on error
+func MustDecimalFromInt(i int64) Decimal {
+	d, err := DecimalFromInt(i)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// ParseDecimal parses a number like "-12.3456" without loss of precision
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	negative := false
+	switch {
+	case strings.HasPrefix(str, "-"):
+		negative = true
+		str = str[1:]
+	case strings.HasPrefix(str, "+"):
+		str = str[1:]
+	}
+
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, errors.Errorf("invalid decimal %q", s)
+	}
+
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > DecimalScale {
+		return 0, errors.Errorf("decimal %q has more than %d fractional digits", s, DecimalScale)
+	}
+	fracPart += strings.Repeat("0", DecimalScale-len(fracPart))
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, errors.Errorf("invalid decimal %q", s)
+		}
+	}
+
+	units, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(ErrDecimalOverflow, "parse %q", s)
+	}
+	if negative {
+		units = -units
+	}
+
+	return Decimal(units), nil
+}
+
+// MustParseDecimal is like ParseDecimal but panics on error
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Add returns d+other
+func (d Decimal) Add(other Decimal) (Decimal, error) {
+	sum := d + other
+	if (other > 0 && sum < d) || (other < 0 && sum > d) {
+		return 0, ErrDecimalOverflow
+	}
+	return sum, nil
+}
+
+// Sub returns d-other
+func (d Decimal) Sub(other Decimal) (Decimal, error) {
+	if other == math.MinInt64 {
+		return 0, ErrDecimalOverflow
+	}
+	return d.Add(-other)
+}
+
+// Mul returns d*n
+func (d Decimal) Mul(n int64) (Decimal, error) {
+	if d == 0 || n == 0 {
+		return 0, nil
+	}
+	product := d * Decimal(n)
+	if product/Decimal(n) != d || (d == -1 && n == math.MinInt64) || (n == -1 && d == math.MinInt64) {
+		return 0, ErrDecimalOverflow
+	}
+	return product, nil
+}
+
+// Round rounds d half away from zero to the given number of fractional digits
+func (d Decimal) Round(places int) (Decimal, error) {
+	if places < 0 || places >= DecimalScale {
+		return d, nil
+	}
+
+	step := Decimal(1)
+	for i := places; i < DecimalScale; i++ {
+		step *= 10
+	}
+
+	remainder := d % step
+	rounded := d - remainder
+	switch {
+	case remainder*2 >= step:
+		return rounded.Add(step)
+	case remainder*2 <= -step:
+		return rounded.Sub(step)
+	}
+	return rounded, nil
+}
+
+// Cmp returns -1, 0 or +1 when d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d < other:
+		return -1
+	case d > other:
+		return 1
+	}
+	return 0
+}
+
+// String formats d with exactly DecimalScale fractional digits
+func (d Decimal) String() string {
+	units := strconv.FormatInt(int64(d), 10)
+	sign := ""
+	if strings.HasPrefix(units, "-") {
+		sign, units = "-", units[1:]
+	}
+	if len(units) <= DecimalScale {
+		units = strings.Repeat("0", DecimalScale-len(units)+1) + units
+	}
+	return sign + units[:len(units)-DecimalScale] + "." + units[len(units)-DecimalScale:]
+}
+
+// Scan implements sql.Scanner
+func (d *Decimal) Scan(src interface{}) error {
+	var (
+		value Decimal
+		err   error
+	)
+
+	switch v := src.(type) {
+	case []byte:
+		value, err = ParseDecimal(string(v))
+	case string:
+		value, err = ParseDecimal(v)
+	case int64:
+		value, err = DecimalFromInt(v)
+	case float64:
+		value, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return errors.Errorf("cannot scan %T into Decimal", src)
+	}
+	if err != nil {
+		return err
+	}
+
+	*d = value
+	return nil
+}
+
+// Value implements driver.Valuer
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
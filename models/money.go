@@ -1,197 +1,289 @@
 package models
 
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrCurrencyMismatch is returned when an operation mixes different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
 // Money is an entity
 type Money struct {
-	Value    float64
+	Value    Decimal
 	Currency Currency
 }
 
+// NewMoney is money constructor
+func NewMoney(value Decimal, currency Currency) Money {
+	return Money{Value: value, Currency: currency}
+}
+
+// Add returns m+other, both amounts must be in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	value, err := m.Value.Add(other.Value)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(value, m.Currency), nil
+}
+
+// Sub returns m-other, both amounts must be in the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	value, err := m.Value.Sub(other.Value)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(value, m.Currency), nil
+}
+
+// Mul returns m multiplied by quantity
+func (m Money) Mul(quantity int) (Money, error) {
+	value, err := m.Value.Mul(int64(quantity))
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(value, m.Currency), nil
+}
+
+// Cmp compares amounts of the same currency, see Decimal.Cmp
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	return m.Value.Cmp(other.Value), nil
+}
+
+// Round rounds the amount to minor units of the currency, e.g. cents for USD
+// and whole yens for JPY
+func (m Money) Round() (Money, error) {
+	if err := m.Currency.Validate(); err != nil {
+		return Money{}, err
+	}
+	value, err := m.Value.Round(m.Currency.MinorUnits())
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(value, m.Currency), nil
+}
+
+// Validate checks that the currency is registered
+func (m Money) Validate() error {
+	return m.Currency.Validate()
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Value == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Value < 0
+}
+
+func (m Money) String() string {
+	return m.Value.String() + " " + string(m.Currency)
+}
+
+func (m Money) sameCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return errors.Wrapf(ErrCurrencyMismatch, "%s and %s", m.Currency, other.Currency)
+	}
+	return nil
+}
+
 // Currency is a value object
 type Currency string
 
 const (
 	AED Currency = "aed"
-	AFN          = "afn"
-	ALL          = "all"
-	AMD          = "amd"
-	ANG          = "ang"
-	AOA          = "aoa"
-	ARS          = "ars"
-	AUD          = "aud"
-	AWG          = "awg"
-	AZN          = "azn"
-	BAM          = "bam"
-	BBD          = "bbd"
-	BDT          = "bdt"
-	BGN          = "bgn"
-	BHD          = "bhd"
-	BIF          = "bif"
-	BMD          = "bmd"
-	BND          = "bnd"
-	BOB          = "bob"
-	BOV          = "bov"
-	BRL          = "brl"
-	BSD          = "bsd"
-	BTC          = "btc"
-	BTN          = "btn"
-	BWP          = "bwp"
-	BYR          = "byr"
-	BZD          = "bzd"
-	CAD          = "cad"
-	CDF          = "cdf"
-	CHE          = "che"
-	CHF          = "chf"
-	CHW          = "chw"
-	CLF          = "clf"
-	CLP          = "clp"
-	CNY          = "cny"
-	COP          = "cop"
-	COU          = "cou"
-	CRC          = "crc"
-	CUC          = "cuc"
-	CUP          = "cup"
-	CVE          = "cve"
-	CZK          = "czk"
-	DJF          = "djf"
-	DKK          = "dkk"
-	DOP          = "dop"
-	DZD          = "dzd"
-	EGP          = "egp"
-	ERN          = "ern"
-	ETB          = "etb"
-	EUR          = "eur"
-	FJD          = "fjd"
-	FKP          = "fkp"
-	GBP          = "gbp"
-	GEL          = "gel"
-	GHS          = "ghs"
-	GIP          = "gip"
-	GMD          = "gmd"
-	GNF          = "gnf"
-	GTQ          = "gtq"
-	GYD          = "gyd"
-	HKD          = "hkd"
-	HNL          = "hnl"
-	HRK          = "hrk"
-	HTG          = "htg"
-	HUF          = "huf"
-	IDR          = "idr"
-	ILS          = "ils"
-	INR          = "inr"
-	IQD          = "iqd"
-	IRR          = "irr"
-	ISK          = "isk"
-	JMD          = "jmd"
-	JOD          = "jod"
-	JPY          = "jpy"
-	KES          = "kes"
-	KGS          = "kgs"
-	KHR          = "khr"
-	KMF          = "kmf"
-	KPW          = "kpw"
-	KRW          = "krw"
-	KWD          = "kwd"
-	KYD          = "kyd"
-	KZT          = "kzt"
-	LAK          = "lak"
-	LBP          = "lbp"
-	LKR          = "lkr"
-	LRD          = "lrd"
-	LSL          = "lsl"
-	LTC          = "ltc"
-	LTL          = "ltl"
-	LVL          = "lvl"
-	LYD          = "lyd"
-	MAD          = "mad"
-	MDL          = "mdl"
-	MGA          = "mga"
-	MKD          = "mkd"
-	MMK          = "mmk"
-	MNT          = "mnt"
-	MOP          = "mop"
-	MRO          = "mro"
-	MUR          = "mur"
-	MVR          = "mvr"
-	MWK          = "mwk"
-	MXN          = "mxn"
-	MXV          = "mxv"
-	MYR          = "myr"
-	MZN          = "mzn"
-	NAD          = "nad"
-	NGN          = "ngn"
-	NIO          = "nio"
-	NOK          = "nok"
-	NPR          = "npr"
-	NZD          = "nzd"
-	OMR          = "omr"
-	PAB          = "pab"
-	PEN          = "pen"
-	PGK          = "pgk"
-	PHP          = "php"
-	PKR          = "pkr"
-	PLN          = "pln"
-	PYG          = "pyg"
-	QAR          = "qar"
-	RON          = "ron"
-	RSD          = "rsd"
-	RUB          = "rub"
-	RWF          = "rwf"
-	SAR          = "sar"
-	SBD          = "sbd"
-	SCR          = "scr"
-	SDG          = "sdg"
-	SEK          = "sek"
-	SGD          = "sgd"
-	SHP          = "shp"
-	SLL          = "sll"
-	SOS          = "sos"
-	SRD          = "srd"
-	SSP          = "ssp"
-	STD          = "std"
-	SVC          = "svc"
-	SYP          = "syp"
-	SZL          = "szl"
-	THB          = "thb"
-	TJS          = "tjs"
-	TMT          = "tmt"
-	TND          = "tnd"
-	TOP          = "top"
-	TRY          = "try"
-	TTD          = "ttd"
-	TWD          = "twd"
-	TZS          = "tzs"
-	UAH          = "uah"
-	UGX          = "ugx"
-	USD          = "usd"
-	USN          = "usn"
-	USS          = "uss"
-	UYI          = "uyi"
-	UYU          = "uyu"
-	UZS          = "uzs"
-	VEF          = "vef"
-	VND          = "vnd"
-	VUV          = "vuv"
-	WST          = "wst"
-	XAF          = "xaf"
-	XAG          = "xag"
-	XAU          = "xau"
-	XBA          = "xba"
-	XBB          = "xbb"
-	XBC          = "xbc"
-	XBD          = "xbd"
-	XCD          = "xcd"
-	XDR          = "xdr"
-	XFU          = "xfu"
-	XOF          = "xof"
-	XPD          = "xpd"
-	XPF          = "xpf"
-	XPT          = "xpt"
-	XSU          = "xsu"
-	XTS          = "xts"
-	XUA          = "xua"
-	XXX          = "xxx"
-	YER          = "yer"
-	ZAR          = "zar"
-	ZMK          = "zmk"
-	ZWL          = "zwl"
+	AFN Currency = "afn"
+	ALL Currency = "all"
+	AMD Currency = "amd"
+	ANG Currency = "ang"
+	AOA Currency = "aoa"
+	ARS Currency = "ars"
+	AUD Currency = "aud"
+	AWG Currency = "awg"
+	AZN Currency = "azn"
+	BAM Currency = "bam"
+	BBD Currency = "bbd"
+	BDT Currency = "bdt"
+	BGN Currency = "bgn"
+	BHD Currency = "bhd"
+	BIF Currency = "bif"
+	BMD Currency = "bmd"
+	BND Currency = "bnd"
+	BOB Currency = "bob"
+	BOV Currency = "bov"
+	BRL Currency = "brl"
+	BSD Currency = "bsd"
+	BTC Currency = "btc"
+	BTN Currency = "btn"
+	BWP Currency = "bwp"
+	BYR Currency = "byr"
+	BZD Currency = "bzd"
+	CAD Currency = "cad"
+	CDF Currency = "cdf"
+	CHE Currency = "che"
+	CHF Currency = "chf"
+	CHW Currency = "chw"
+	CLF Currency = "clf"
+	CLP Currency = "clp"
+	CNY Currency = "cny"
+	COP Currency = "cop"
+	COU Currency = "cou"
+	CRC Currency = "crc"
+	CUC Currency = "cuc"
+	CUP Currency = "cup"
+	CVE Currency = "cve"
+	CZK Currency = "czk"
+	DJF Currency = "djf"
+	DKK Currency = "dkk"
+	DOP Currency = "dop"
+	DZD Currency = "dzd"
+	EGP Currency = "egp"
+	ERN Currency = "ern"
+	ETB Currency = "etb"
+	EUR Currency = "eur"
+	FJD Currency = "fjd"
+	FKP Currency = "fkp"
+	GBP Currency = "gbp"
+	GEL Currency = "gel"
+	GHS Currency = "ghs"
+	GIP Currency = "gip"
+	GMD Currency = "gmd"
+	GNF Currency = "gnf"
+	GTQ Currency = "gtq"
+	GYD Currency = "gyd"
+	HKD Currency = "hkd"
+	HNL Currency = "hnl"
+	HRK Currency = "hrk"
+	HTG Currency = "htg"
+	HUF Currency = "huf"
+	IDR Currency = "idr"
+	ILS Currency = "ils"
+	INR Currency = "inr"
+	IQD Currency = "iqd"
+	IRR Currency = "irr"
+	ISK Currency = "isk"
+	JMD Currency = "jmd"
+	JOD Currency = "jod"
+	JPY Currency = "jpy"
+	KES Currency = "kes"
+	KGS Currency = "kgs"
+	KHR Currency = "khr"
+	KMF Currency = "kmf"
+	KPW Currency = "kpw"
+	KRW Currency = "krw"
+	KWD Currency = "kwd"
+	KYD Currency = "kyd"
+	KZT Currency = "kzt"
+	LAK Currency = "lak"
+	LBP Currency = "lbp"
+	LKR Currency = "lkr"
+	LRD Currency = "lrd"
+	LSL Currency = "lsl"
+	LTC Currency = "ltc"
+	LTL Currency = "ltl"
+	LVL Currency = "lvl"
+	LYD Currency = "lyd"
+	MAD Currency = "mad"
+	MDL Currency = "mdl"
+	MGA Currency = "mga"
+	MKD Currency = "mkd"
+	MMK Currency = "mmk"
+	MNT Currency = "mnt"
+	MOP Currency = "mop"
+	MRO Currency = "mro"
+	MUR Currency = "mur"
+	MVR Currency = "mvr"
+	MWK Currency = "mwk"
+	MXN Currency = "mxn"
+	MXV Currency = "mxv"
+	MYR Currency = "myr"
+	MZN Currency = "mzn"
+	NAD Currency = "nad"
+	NGN Currency = "ngn"
+	NIO Currency = "nio"
+	NOK Currency = "nok"
+	NPR Currency = "npr"
+	NZD Currency = "nzd"
+	OMR Currency = "omr"
+	PAB Currency = "pab"
+	PEN Currency = "pen"
+	PGK Currency = "pgk"
+	PHP Currency = "php"
+	PKR Currency = "pkr"
+	PLN Currency = "pln"
+	PYG Currency = "pyg"
+	QAR Currency = "qar"
+	RON Currency = "ron"
+	RSD Currency = "rsd"
+	RUB Currency = "rub"
+	RWF Currency = "rwf"
+	SAR Currency = "sar"
+	SBD Currency = "sbd"
+	SCR Currency = "scr"
+	SDG Currency = "sdg"
+	SEK Currency = "sek"
+	SGD Currency = "sgd"
+	SHP Currency = "shp"
+	SLL Currency = "sll"
+	SOS Currency = "sos"
+	SRD Currency = "srd"
+	SSP Currency = "ssp"
+	STD Currency = "std"
+	SVC Currency = "svc"
+	SYP Currency = "syp"
+	SZL Currency = "szl"
+	THB Currency = "thb"
+	TJS Currency = "tjs"
+	TMT Currency = "tmt"
+	TND Currency = "tnd"
+	TOP Currency = "top"
+	TRY Currency = "try"
+	TTD Currency = "ttd"
+	TWD Currency = "twd"
+	TZS Currency = "tzs"
+	UAH Currency = "uah"
+	UGX Currency = "ugx"
+	USD Currency = "usd"
+	USN Currency = "usn"
+	USS Currency = "uss"
+	UYI Currency = "uyi"
+	UYU Currency = "uyu"
+	UZS Currency = "uzs"
+	VEF Currency = "vef"
+	VND Currency = "vnd"
+	VUV Currency = "vuv"
+	WST Currency = "wst"
+	XAF Currency = "xaf"
+	XAG Currency = "xag"
+	XAU Currency = "xau"
+	XBA Currency = "xba"
+	XBB Currency = "xbb"
+	XBC Currency = "xbc"
+	XBD Currency = "xbd"
+	XCD Currency = "xcd"
+	XDR Currency = "xdr"
+	XFU Currency = "xfu"
+	XOF Currency = "xof"
+	XPD Currency = "xpd"
+	XPF Currency = "xpf"
+	XPT Currency = "xpt"
+	XSU Currency = "xsu"
+	XTS Currency = "xts"
+	XUA Currency = "xua"
+	XXX Currency = "xxx"
+	YER Currency = "yer"
+	ZAR Currency = "zar"
+	ZMK Currency = "zmk"
+	ZWL Currency = "zwl"
 )
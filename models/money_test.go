@@ -0,0 +1,138 @@
+// +build unit
+
+package models
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		for input, expected := range map[string]Decimal{
+			"0":                    0,
+			"12":                   120000,
+			"-12.5":                -125000,
+			"+0.0001":              1,
+			".5":                   5000,
+			"3.100000":             31000,
+			"922337203685477.5807": math.MaxInt64,
+		} {
+			d, err := ParseDecimal(input)
+			require.NoError(t, err, input)
+			require.Equal(t, expected, d, input)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, input := range []string{"", "-", ".", "1.2.3", "abc", "1e5", "0.00001", "922337203685477.5808"} {
+			_, err := ParseDecimal(input)
+			require.Error(t, err, input)
+		}
+	})
+}
+
+func TestDecimalFromInt(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		for input, expected := range map[int64]string{
+			0:                "0.0000",
+			-12:              "-12.0000",
+			922337203685477:  "922337203685477.0000",
+			-922337203685477: "-922337203685477.0000",
+		} {
+			d, err := DecimalFromInt(input)
+			require.NoError(t, err, input)
+			require.Equal(t, expected, d.String(), input)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, input := range []int64{922337203685478, -922337203685478, math.MaxInt64, math.MinInt64} {
+			_, err := DecimalFromInt(input)
+			require.Equal(t, ErrDecimalOverflow, errors.Cause(err), input)
+		}
+	})
+}
+
+func TestDecimal_String(t *testing.T) {
+	require.Equal(t, "0.0000", Decimal(0).String())
+	require.Equal(t, "0.0001", Decimal(1).String())
+	require.Equal(t, "-0.0500", Decimal(-500).String())
+	require.Equal(t, "12.3456", Decimal(123456).String())
+}
+
+func TestDecimal_Scan(t *testing.T) {
+	var d Decimal
+
+	require.NoError(t, d.Scan([]byte("10.0100")))
+	require.Equal(t, MustParseDecimal("10.01"), d)
+
+	require.NoError(t, d.Scan(int64(7)))
+	require.Equal(t, MustDecimalFromInt(7), d)
+
+	require.NoError(t, d.Scan(0.1))
+	require.Equal(t, MustParseDecimal("0.1"), d)
+
+	require.Error(t, d.Scan(nil))
+	require.Error(t, d.Scan("0.00001"))
+
+	value, err := MustParseDecimal("-3.25").Value()
+	require.NoError(t, err)
+	require.Equal(t, "-3.2500", value)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	tenth := NewMoney(MustParseDecimal("0.1"), USD)
+
+	t.Run("sums are exact", func(t *testing.T) {
+		sum := NewMoney(0, USD)
+		for i := 0; i < 10; i++ {
+			var err error
+			sum, err = sum.Add(tenth)
+			require.NoError(t, err)
+		}
+		require.Equal(t, NewMoney(MustDecimalFromInt(1), USD), sum)
+
+		diff, err := sum.Sub(NewMoney(MustDecimalFromInt(1), USD))
+		require.NoError(t, err)
+		require.True(t, diff.IsZero())
+	})
+
+	t.Run("mul", func(t *testing.T) {
+		total, err := tenth.Mul(3)
+		require.NoError(t, err)
+		require.Equal(t, "0.3000 usd", total.String())
+
+		_, err = NewMoney(Decimal(1<<62), USD).Mul(4)
+		require.Equal(t, ErrDecimalOverflow, errors.Cause(err))
+	})
+
+	t.Run("cmp and sign", func(t *testing.T) {
+		negative, err := tenth.Sub(NewMoney(MustDecimalFromInt(1), USD))
+		require.NoError(t, err)
+		require.True(t, negative.IsNegative())
+		require.False(t, tenth.IsNegative())
+
+		cmp, err := negative.Cmp(tenth)
+		require.NoError(t, err)
+		require.Equal(t, -1, cmp)
+	})
+
+	t.Run("currencies are never mixed", func(t *testing.T) {
+		euro := NewMoney(MustDecimalFromInt(1), EUR)
+
+		_, err := tenth.Add(euro)
+		require.Equal(t, ErrCurrencyMismatch, errors.Cause(err))
+		_, err = tenth.Sub(euro)
+		require.Equal(t, ErrCurrencyMismatch, errors.Cause(err))
+		_, err = tenth.Cmp(euro)
+		require.Equal(t, ErrCurrencyMismatch, errors.Cause(err))
+	})
+
+	t.Run("add overflow", func(t *testing.T) {
+		_, err := NewMoney(Decimal(math.MaxInt64), USD).Add(tenth)
+		require.Equal(t, ErrDecimalOverflow, errors.Cause(err))
+	})
+}
@@ -1,12 +1,153 @@
 package models
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidOrder is matched by errors returned from Order.Validate
+var ErrInvalidOrder = errors.New("invalid order")
+
 // OrderID is a value object
 type OrderID int
 
 // Order is an entity
 type Order struct {
-	ID         OrderID
+	ID OrderID
+	// PublicID identifies the order outside, e.g. in URLs
+	PublicID   string
 	CustomerID CustomerID
 	Amount     Money
-	Items      []*OrderItem
+	Status     OrderStatus
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// Version is bumped by every change of the stored order
+	Version int64
+	// DeletedAt is set when the order is soft deleted
+	DeletedAt *time.Time
+	Items     []*OrderItem
+}
+
+// Violation is a single inconsistency found in an order
+type Violation struct {
+	Field   string
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+// OrderValidationError lists every violation found in an order
+type OrderValidationError struct {
+	Violations []Violation
+}
+
+func (e *OrderValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.String()
+	}
+	return ErrInvalidOrder.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Is matches e with ErrInvalidOrder
+func (e *OrderValidationError) Is(target error) bool {
+	return target == ErrInvalidOrder
+}
+
+// Validate checks that the order has items, that every item is in the order
+// currency with a positive quantity and a non-negative price, and that Amount
+// is the exact sum of item prices multiplied by quantities.
+// All violations are reported at once in *OrderValidationError.
+func (o *Order) Validate() error {
+	var violations []Violation
+	violate := func(field, format string, args ...interface{}) {
+		violations = append(violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if err := o.Amount.Validate(); err != nil {
+		violate("Amount.Currency", "%v", err)
+	}
+	if o.Amount.IsNegative() {
+		violate("Amount", "negative amount %s", o.Amount)
+	}
+	if err := o.Status.Validate(); o.Status != "" && err != nil {
+		violate("Status", "%v", err)
+	}
+	if len(o.Items) == 0 {
+		violate("Items", "order has no items")
+	}
+
+	consistent := true
+	for i, item := range o.Items {
+		field := fmt.Sprintf("Items[%d]", i)
+		if item == nil {
+			violate(field, "missing item")
+			consistent = false
+			continue
+		}
+		if item.Price.Currency != o.Amount.Currency {
+			violate(field+".Price.Currency", "%s differs from order currency %s", item.Price.Currency, o.Amount.Currency)
+			consistent = false
+		}
+		if item.Price.IsNegative() {
+			violate(field+".Price", "negative price %s", item.Price)
+		}
+		if item.Quantity <= 0 {
+			violate(field+".Quantity", "quantity %d is not positive", item.Quantity)
+		}
+	}
+
+	if consistent && len(o.Items) > 0 {
+		total, err := o.total()
+		switch {
+		case err != nil:
+			violate("Amount", "%v", err)
+		case total.Value != o.Amount.Value:
+			violate("Amount", "%s does not match items total %s", o.Amount, total)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &OrderValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// RecalculateAmount sets Amount to the sum of item prices multiplied by quantities.
+// The currency is kept when set, otherwise it is taken from the first item.
+// Amount is left intact when items mix currencies or the sum overflows.
+func (o *Order) RecalculateAmount() error {
+	total, err := o.total()
+	if err != nil {
+		return err
+	}
+	o.Amount = total
+	return nil
+}
+
+func (o *Order) total() (Money, error) {
+	total := Money{Currency: o.Amount.Currency}
+	if total.Currency == "" && len(o.Items) > 0 && o.Items[0] != nil {
+		total.Currency = o.Items[0].Price.Currency
+	}
+
+	for i, item := range o.Items {
+		if item == nil {
+			return Money{}, errors.Errorf("item %d is missing", i)
+		}
+		price, err := item.Price.Mul(item.Quantity)
+		if err != nil {
+			return Money{}, errors.Wrapf(err, "item %d", i)
+		}
+		if total, err = total.Add(price); err != nil {
+			return Money{}, errors.Wrapf(err, "item %d", i)
+		}
+	}
+
+	return total, nil
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // OrderItem is an entity
 type OrderItem struct {
 	ID        int64
@@ -7,4 +9,8 @@ type OrderItem struct {
 	ProductID ProductID
 	Quantity  int
 	Price     Money
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is set when the item is soft deleted together with its order
+	DeletedAt *time.Time
 }
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"github.com/pkg/errors"
+)
+
+// OrderStatus is a value object, a stage of the order lifecycle
+type OrderStatus string
+
+// Order statuses
+const (
+	OrderStatusDraft     OrderStatus = "draft"
+	OrderStatusPlaced    OrderStatus = "placed"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusShipped   OrderStatus = "shipped"
+	OrderStatusDelivered OrderStatus = "delivered"
+	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusRefunded  OrderStatus = "refunded"
+)
+
+var (
+	// ErrUnknownOrderStatus is returned for a status missing in the transition table
+	ErrUnknownOrderStatus = errors.New("unknown order status")
+	// ErrIllegalTransition is returned when an order can not move from one status to another
+	ErrIllegalTransition = errors.New("illegal order status transition")
+)
+
+// orderTransitions lists statuses an order may move to from each status.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusDraft:     {OrderStatusPlaced, OrderStatusCancelled},
+	OrderStatusPlaced:    {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+	OrderStatusCancelled: {},
+	OrderStatusRefunded:  {},
+}
+
+// Validate checks that the status is known
+func (s OrderStatus) Validate() error {
+	if _, ok := orderTransitions[s]; !ok {
+		return errors.Wrapf(ErrUnknownOrderStatus, "%q", string(s))
+	}
+	return nil
+}
+
+// IsFinal reports whether no transition is possible from the status
+func (s OrderStatus) IsFinal() bool {
+	return len(orderTransitions[s]) == 0
+}
+
+// CanTransitionTo reports whether an order may move from s to the status
+func (s OrderStatus) CanTransitionTo(status OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition returns ErrIllegalTransition when an order can not move from s to the status
+func (s OrderStatus) ValidateTransition(status OrderStatus) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if err := status.Validate(); err != nil {
+		return err
+	}
+	if !s.CanTransitionTo(status) {
+		return errors.Wrapf(ErrIllegalTransition, "%s -> %s", s, status)
+	}
+	return nil
+}
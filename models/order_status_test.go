@@ -0,0 +1,49 @@
+// +build unit
+
+package models
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOrderStatus_ValidateTransition(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		for _, tc := range [][2]OrderStatus{
+			{OrderStatusDraft, OrderStatusPlaced},
+			{OrderStatusPlaced, OrderStatusPaid},
+			{OrderStatusPaid, OrderStatusShipped},
+			{OrderStatusShipped, OrderStatusDelivered},
+			{OrderStatusDraft, OrderStatusCancelled},
+			{OrderStatusPlaced, OrderStatusCancelled},
+			{OrderStatusPaid, OrderStatusRefunded},
+			{OrderStatusDelivered, OrderStatusRefunded},
+		} {
+			require.NoError(t, tc[0].ValidateTransition(tc[1]), "%s -> %s", tc[0], tc[1])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range [][2]OrderStatus{
+			{OrderStatusDraft, OrderStatusPaid},
+			{OrderStatusPaid, OrderStatusPlaced},
+			{OrderStatusPaid, OrderStatusCancelled},
+			{OrderStatusCancelled, OrderStatusPlaced},
+			{OrderStatusRefunded, OrderStatusPaid},
+			{OrderStatusDelivered, OrderStatusDelivered},
+		} {
+			err := tc[0].ValidateTransition(tc[1])
+			require.True(t, errors.Is(err, ErrIllegalTransition), "%s -> %s", tc[0], tc[1])
+		}
+
+		err := OrderStatusDraft.ValidateTransition("lost")
+		require.True(t, errors.Is(err, ErrUnknownOrderStatus))
+	})
+}
+
+func TestOrderStatus_IsFinal(t *testing.T) {
+	require.True(t, OrderStatusCancelled.IsFinal())
+	require.True(t, OrderStatusRefunded.IsFinal())
+	require.False(t, OrderStatusDelivered.IsFinal())
+}
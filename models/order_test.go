@@ -0,0 +1,83 @@
+// +build unit
+
+package models
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestOrder() *Order {
+	return &Order{
+		CustomerID: 1,
+		Amount:     Money{Value: MustParseDecimal("20.5"), Currency: USD},
+		Items: []*OrderItem{
+			{ProductID: 1, Quantity: 1, Price: Money{Value: MustParseDecimal("8.1"), Currency: USD}},
+			{ProductID: 2, Quantity: 2, Price: Money{Value: MustParseDecimal("6.2"), Currency: USD}},
+		},
+	}
+}
+
+func TestOrder_Validate(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, newTestOrder().Validate())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("every violation is reported", func(t *testing.T) {
+			order := newTestOrder()
+			order.Items[0].Price.Currency = EUR
+			order.Items[1].Quantity = -1
+
+			err := order.Validate()
+			require.True(t, errors.Is(err, ErrInvalidOrder))
+
+			var validationError *OrderValidationError
+			require.True(t, errors.As(err, &validationError))
+			require.Equal(t, []Violation{
+				{Field: "Items[0].Price.Currency", Message: "eur differs from order currency usd"},
+				{Field: "Items[1].Quantity", Message: "quantity -1 is not positive"},
+			}, validationError.Violations)
+		})
+
+		t.Run("total mismatch", func(t *testing.T) {
+			order := newTestOrder()
+			order.Amount.Value = MustParseDecimal("20.4999")
+
+			var validationError *OrderValidationError
+			require.True(t, errors.As(order.Validate(), &validationError))
+			require.Equal(t, []Violation{
+				{Field: "Amount", Message: "20.4999 usd does not match items total 20.5000 usd"},
+			}, validationError.Violations)
+		})
+
+		t.Run("no items", func(t *testing.T) {
+			order := &Order{Amount: Money{Currency: USD}}
+
+			var validationError *OrderValidationError
+			require.True(t, errors.As(order.Validate(), &validationError))
+			require.Equal(t, "Items", validationError.Violations[0].Field)
+		})
+	})
+}
+
+func TestOrder_RecalculateAmount(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		order := newTestOrder()
+		order.Amount = Money{}
+		require.NoError(t, order.RecalculateAmount())
+		require.Equal(t, Money{Value: MustParseDecimal("20.5"), Currency: USD}, order.Amount)
+		require.NoError(t, order.Validate())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		order := newTestOrder()
+		order.Items[1].Price.Currency = EUR
+		amount := order.Amount
+
+		err := order.RecalculateAmount()
+		require.True(t, errors.Is(err, ErrCurrencyMismatch))
+		require.Equal(t, amount, order.Amount)
+	})
+}
@@ -1,10 +1,20 @@
 package models
 
+import (
+	"time"
+)
+
 // ProductID is value object
 type ProductID int
 
 // Product is an entity
 type Product struct {
-	ID    ProductID
-	Price Money
+	ID          ProductID
+	SKU         string
+	Name        string
+	Description string
+	Price       Money
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
@@ -0,0 +1,20 @@
+package repositories
+
+import "time"
+
+// Clock tells repositories the time to stamp entities with,
+// tests replace it to get deterministic timestamps
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock
+type ClockFunc func() time.Time
+
+// Now calls f
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is a Clock which repositories use by default
+var SystemClock Clock = ClockFunc(time.Now)
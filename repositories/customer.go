@@ -0,0 +1,23 @@
+//go:generate mockgen -source=customer.go -package repositories -destination customer_mock.go
+
+package repositories
+
+import (
+	"context"
+
+	"github.com/netology/dao-pattern/models"
+)
+
+// CustomerRepository is a repository. Public IDs of customers are set
+// by Save the same way as public IDs of orders.
+type CustomerRepository interface {
+	GetByID(ctx context.Context, customerID models.CustomerID) (*models.Customer, error)
+	GetByPublicID(ctx context.Context, publicID string) (*models.Customer, error)
+	Save(ctx context.Context, customer *models.Customer) error
+	Update(ctx context.Context, customer *models.Customer) error
+	List(ctx context.Context, limit, offset int) ([]*models.Customer, error)
+	// AdjustBalance atomically adds delta to the customer balance and returns the new balance
+	AdjustBalance(ctx context.Context, customerID models.CustomerID, delta models.Money) (models.Money, error)
+	// Close releases prepared statements, later calls fail with ErrClosed
+	Close() error
+}
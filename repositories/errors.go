@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Errors returned by repositories, check them with errors.Is
+var (
+	// ErrNotFound means a requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means an entity violates a unique constraint
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidReference means an entity refers to a missing one
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrValidation means an entity was rejected as invalid
+	ErrValidation = errors.New("validation failed")
+	// ErrSerialization means a transaction was aborted because of concurrent
+	// changes or a deadlock, it is safe to retry
+	ErrSerialization = errors.New("serialization failure")
+	// ErrConnectionLost means a connection to the storage broke
+	ErrConnectionLost = errors.New("connection lost")
+	// ErrStaleStatus means an entity is no longer in the status a transition expected
+	ErrStaleStatus = errors.New("stale status")
+	// ErrStaleVersion means an entity was changed since the version the caller read
+	ErrStaleVersion = errors.New("stale version")
+	// ErrClosed means a repository was used after Close
+	ErrClosed = errors.New("repository is closed")
+	// ErrReadOnly means a write was attempted in a read-only transaction
+	ErrReadOnly = errors.New("read-only transaction")
+	// ErrIdempotencyKeyReused means an idempotency key was replayed with another request
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused")
+)
+
+// Error is a storage failure classified as one of the errors above.
+// For ErrInvalidReference Entity names the missing entity ("customer",
+// "product" or "order") and Key holds its identifier when known.
+type Error struct {
+	Kind       error
+	Constraint string
+	Entity     string
+	Key        string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	msg := e.Kind.Error()
+	if e.Entity != "" {
+		msg += " to " + e.Entity
+		if e.Key != "" {
+			msg += " " + e.Key
+		}
+	}
+	if e.Constraint != "" {
+		msg += " (" + e.Constraint + ")"
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches e with its kind
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// IDGenerator makes public IDs of entities. A public ID is safe to show in
+// URLs, it is generated before insert unlike the serial key of the entity.
+// Public IDs of decimal digits only are reserved for serial keys, see IsSerialPublicID.
+type IDGenerator interface {
+	// NewID returns a new public ID, an empty one leaves the public ID
+	// to the serial key which the database assigns on insert
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc adapts a function to IDGenerator
+type IDGeneratorFunc func() (string, error)
+
+// NewID calls f
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// SerialIDGenerator makes the serial key the public ID, repositories use it by default
+var SerialIDGenerator IDGenerator = IDGeneratorFunc(func() (string, error) {
+	return "", nil
+})
+
+// IsSerialPublicID tells if id is made of decimal digits only, such public IDs
+// belong to entities keyed by the serial ID and callers may not assign them
+func IsSerialPublicID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// UUIDv4Generator makes random UUIDs
+var UUIDv4Generator IDGenerator = IDGeneratorFunc(func() (string, error) {
+	var uuid [16]byte
+	if err := readRandom(uuid[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(uuid, 4), nil
+})
+
+// NewUUIDv7Generator makes UUIDs starting with the time of clock in milliseconds,
+// so they sort in the order they were made
+func NewUUIDv7Generator(clock Clock) IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		var uuid [16]byte
+		putMillis(uuid[:6], clock)
+		if err := readRandom(uuid[6:]); err != nil {
+			return "", err
+		}
+		return formatUUID(uuid, 7), nil
+	})
+}
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULIDGenerator makes ULIDs, 26 characters of the time of clock in milliseconds
+// followed by random bits, which sort in the order they were made
+func NewULIDGenerator(clock Clock) IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		var ulid [16]byte
+		putMillis(ulid[:6], clock)
+		if err := readRandom(ulid[6:]); err != nil {
+			return "", err
+		}
+
+		// 128 bits are encoded by 5 bits from the most significant end,
+		// the first character takes the 3 bits which are left over
+		hi, lo := binary.BigEndian.Uint64(ulid[:8]), binary.BigEndian.Uint64(ulid[8:])
+		id := make([]byte, 26)
+		for i := len(id) - 1; i >= 0; i-- {
+			id[i] = crockford[lo&0x1f]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+		return string(id), nil
+	})
+}
+
+// putMillis writes 48 bits of the Unix time of clock in milliseconds
+func putMillis(b []byte, clock Clock) {
+	millis := uint64(clock.Now().UnixNano() / 1e6)
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(millis)
+		millis >>= 8
+	}
+}
+
+func readRandom(b []byte) error {
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return errors.Wrap(err, "read random error")
+	}
+	return nil
+}
+
+// formatUUID sets the version and the RFC 4122 variant and formats the UUID
+func formatUUID(uuid [16]byte, version byte) string {
+	uuid[6] = uuid[6]&0x0f | version<<4
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	id := make([]byte, 36)
+	hex.Encode(id[0:8], uuid[0:4])
+	id[8] = '-'
+	hex.Encode(id[9:13], uuid[4:6])
+	id[13] = '-'
+	hex.Encode(id[14:18], uuid[6:8])
+	id[18] = '-'
+	hex.Encode(id[19:23], uuid[8:10])
+	id[23] = '-'
+	hex.Encode(id[24:], uuid[10:])
+	return string(id)
+}
@@ -0,0 +1,61 @@
+// +build unit
+
+package repositories
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestIDGenerators(t *testing.T) {
+	clock := ClockFunc(func() time.Time {
+		return time.Unix(0, 1469918176385*int64(time.Millisecond))
+	})
+
+	t.Run("serial", func(t *testing.T) {
+		id, err := SerialIDGenerator.NewID()
+		require.NoError(t, err)
+		require.Empty(t, id)
+	})
+
+	t.Run("uuid v4", func(t *testing.T) {
+		first, err := UUIDv4Generator.NewID()
+		require.NoError(t, err)
+		require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first)
+
+		second, err := UUIDv4Generator.NewID()
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+	})
+
+	t.Run("uuid v7", func(t *testing.T) {
+		id, err := NewUUIDv7Generator(clock).NewID()
+		require.NoError(t, err)
+		require.Regexp(t, `^01563df3-6481-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, id)
+	})
+
+	t.Run("ulid", func(t *testing.T) {
+		generator := NewULIDGenerator(clock)
+		first, err := generator.NewID()
+		require.NoError(t, err)
+		require.Regexp(t, `^01ARYZ6S41[0-9A-HJKMNP-TV-Z]{16}$`, first)
+
+		second, err := generator.NewID()
+		require.NoError(t, err)
+		require.NotEqual(t, first, second)
+	})
+}
+
+func TestIsSerialPublicID(t *testing.T) {
+	for id, expected := range map[string]bool{
+		"":         false,
+		"7":        true,
+		"0042":     true,
+		"-7":       false,
+		"order-7":  false,
+		"01ARYZ6S": false,
+	} {
+		require.Equal(t, expected, IsSerialPublicID(id), id)
+	}
+}
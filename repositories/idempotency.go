@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/netology/dao-pattern/models"
+)
+
+// DefaultIdempotencyKeyTTL is how long an idempotency key replays the order
+// it created, an expired key is free to create another order
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// OrderRequestHash fingerprints the order a caller asks to save, so a replayed
+// idempotency key can tell a retry of the same request from a different order.
+// Only the fields the caller sets count, IDs and timestamps are left out.
+func OrderRequestHash(order *models.Order) string {
+	status := order.Status
+	if status == "" {
+		status = models.OrderStatusDraft
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d|%s|%s|%s\n", order.CustomerID, order.Amount.Value, order.Amount.Currency, status)
+	for _, item := range order.Items {
+		fmt.Fprintf(hash, "%d|%d|%s|%s\n", item.ProductID, item.Quantity, item.Price.Value, item.Price.Currency)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,32 @@
+// +build unit
+
+package repositories
+
+import (
+	"github.com/netology/dao-pattern/models"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOrderRequestHash(t *testing.T) {
+	order := func() *models.Order {
+		return &models.Order{
+			CustomerID: 1,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+			Items: []*models.OrderItem{
+				{ProductID: 1, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD}},
+			},
+		}
+	}
+	hash := OrderRequestHash(order())
+
+	saved := order()
+	saved.ID, saved.Status, saved.CreatedAt = 7, models.OrderStatusDraft, time.Now()
+	saved.Items[0].ID = 3
+	require.Equal(t, hash, OrderRequestHash(saved))
+
+	changed := order()
+	changed.Items[0].Quantity = 2
+	require.NotEqual(t, hash, OrderRequestHash(changed))
+}
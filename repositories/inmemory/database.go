@@ -0,0 +1,414 @@
+// Package inmemory implements repositories on top of Go maps. It is meant for
+// tests and prototyping and mimics the constraints of the postgresql backend.
+package inmemory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// Database is a storage shared by in-memory repositories, it is safe for
+// concurrent use. Entities are copied on the way in and out, so callers never
+// share memory with the storage. Every write bumps the version, which tells
+// a committing transaction whether the database changed since it began.
+type Database struct {
+	mu              sync.RWMutex
+	orders          map[models.OrderID]*models.Order
+	orderItems      map[int64]*models.OrderItem
+	lastOrderID     models.OrderID
+	lastOrderItemID int64
+	version         uint64
+	readOnly        bool
+	idempotencyKeys map[string]idempotencyKey
+	idempotencyTTL  time.Duration
+	clock           repositories.Clock
+	idGenerator     repositories.IDGenerator
+}
+
+// idempotencyKey is a key taken by SaveIdempotent
+type idempotencyKey struct {
+	requestHash string
+	orderID     models.OrderID
+	createdAt   time.Time
+}
+
+// Option configures a Database
+type Option func(*Database)
+
+// WithClock makes the database stamp entities with the time of clock
+// instead of repositories.SystemClock
+func WithClock(clock repositories.Clock) Option {
+	return func(d *Database) {
+		d.clock = clock
+	}
+}
+
+// WithIDGenerator makes the database set public IDs of new orders with generator
+// instead of repositories.SerialIDGenerator
+func WithIDGenerator(generator repositories.IDGenerator) Option {
+	return func(d *Database) {
+		d.idGenerator = generator
+	}
+}
+
+// WithIdempotencyKeyTTL makes SaveIdempotent replay orders for ttl
+// instead of repositories.DefaultIdempotencyKeyTTL
+func WithIdempotencyKeyTTL(ttl time.Duration) Option {
+	return func(d *Database) {
+		d.idempotencyTTL = ttl
+	}
+}
+
+func NewDatabase(options ...Option) *Database {
+	d := &Database{
+		orders:          map[models.OrderID]*models.Order{},
+		orderItems:      map[int64]*models.OrderItem{},
+		idempotencyKeys: map[string]idempotencyKey{},
+		idempotencyTTL:  repositories.DefaultIdempotencyKeyTTL,
+		clock:           repositories.SystemClock,
+		idGenerator:     repositories.SerialIDGenerator,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// now returns the time of the clock the way the postgresql backend stores
+// timestamps, in UTC with microsecond precision
+func (d *Database) now() time.Time {
+	return d.clock.Now().UTC().Truncate(time.Microsecond)
+}
+
+// newPublicID returns the public ID the caller assigned or generates one,
+// which is empty when it is left to the serial key. An assigned serial
+// public ID is rejected as it would take the one of a later insert.
+func (d *Database) newPublicID(assigned string) (string, error) {
+	if repositories.IsSerialPublicID(assigned) {
+		return "", validationError(errors.Errorf("public id %q is reserved for serial keys", assigned))
+	}
+	if assigned != "" {
+		return assigned, nil
+	}
+	id, err := d.idGenerator.NewID()
+	if err != nil {
+		return "", errors.Wrap(err, "generate public id error")
+	}
+	return id, nil
+}
+
+// lock takes the write lock for a write, which fails in a read-only transaction
+func (d *Database) lock() error {
+	if d.readOnly {
+		return &repositories.Error{
+			Kind: repositories.ErrReadOnly,
+			Err:  errors.New("write in a read-only transaction"),
+		}
+	}
+	d.mu.Lock()
+	d.version++
+	return nil
+}
+
+// clone returns a deep copy of the database, the caller must hold the lock
+func (d *Database) clone() *Database {
+	c := &Database{
+		orders:          make(map[models.OrderID]*models.Order, len(d.orders)),
+		orderItems:      make(map[int64]*models.OrderItem, len(d.orderItems)),
+		idempotencyKeys: make(map[string]idempotencyKey, len(d.idempotencyKeys)),
+		lastOrderID:     d.lastOrderID,
+		lastOrderItemID: d.lastOrderItemID,
+		version:         d.version,
+		readOnly:        d.readOnly,
+		idempotencyTTL:  d.idempotencyTTL,
+		clock:           d.clock,
+		idGenerator:     d.idGenerator,
+	}
+	for id, order := range d.orders {
+		c.orders[id] = copyOrder(order)
+	}
+	for id, item := range d.orderItems {
+		c.orderItems[id] = copyOrderItem(item)
+	}
+	for key, taken := range d.idempotencyKeys {
+		c.idempotencyKeys[key] = taken
+	}
+	return c
+}
+
+// restore brings back the contents of a clone
+func (d *Database) restore(snapshot *Database) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.orders = snapshot.orders
+	d.orderItems = snapshot.orderItems
+	d.idempotencyKeys = snapshot.idempotencyKeys
+	d.lastOrderID = snapshot.lastOrderID
+	d.lastOrderItemID = snapshot.lastOrderItemID
+}
+
+// itemsByOrderID returns copies of the order items ordered by ID, soft deleted
+// ones only when includeDeleted is set. The caller must hold the lock.
+func (d *Database) itemsByOrderID(orderID models.OrderID, includeDeleted bool) []*models.OrderItem {
+	orderItems := []*models.OrderItem{}
+	for _, item := range d.orderItems {
+		if item.OrderID == orderID && (includeDeleted || item.DeletedAt == nil) {
+			orderItems = append(orderItems, copyOrderItem(item))
+		}
+	}
+	sort.Slice(orderItems, func(i, j int) bool {
+		return orderItems[i].ID < orderItems[j].ID
+	})
+	return orderItems
+}
+
+// live returns the stored order unless it is missing or soft deleted,
+// the caller must hold the lock
+func (d *Database) live(orderID models.OrderID) (*models.Order, bool) {
+	order, ok := d.orders[orderID]
+	if !ok || order.DeletedAt != nil {
+		return nil, false
+	}
+	return order, true
+}
+
+// orderByPublicID finds an order by its public ID, the caller must hold the lock
+func (d *Database) orderByPublicID(publicID string) (*models.Order, bool) {
+	for _, order := range d.orders {
+		if order.PublicID == publicID {
+			return order, true
+		}
+	}
+	return nil, false
+}
+
+// insertOrder stores a new order with its items, the caller must hold the lock
+func (d *Database) insertOrder(order *models.Order, publicID string) error {
+	if publicID == "" {
+		publicID = strconv.Itoa(int(d.lastOrderID + 1))
+	}
+	if _, ok := d.orderByPublicID(publicID); ok {
+		return duplicatePublicIDError(publicID)
+	}
+
+	d.lastOrderID++
+	order.ID = d.lastOrderID
+	order.PublicID = publicID
+	order.CreatedAt = d.now()
+	order.UpdatedAt = order.CreatedAt
+	order.Version = 1
+	d.orders[order.ID] = copyOrder(order)
+
+	for _, item := range order.Items {
+		item.OrderID = order.ID
+		if err := d.insertOrderItem(item); err != nil {
+			return errors.Wrap(err, "save order item error")
+		}
+	}
+
+	return nil
+}
+
+// deleteOrder deletes the order with its items and idempotency keys
+// the way foreign keys cascade, the caller must hold the lock
+func (d *Database) deleteOrder(orderID models.OrderID) {
+	d.deleteOrderItems(orderID)
+	delete(d.orders, orderID)
+	for key, taken := range d.idempotencyKeys {
+		if taken.orderID == orderID {
+			delete(d.idempotencyKeys, key)
+		}
+	}
+}
+
+// insertOrderItem stores a copy of orderItem under a new ID,
+// the caller must hold the write lock
+func (d *Database) insertOrderItem(orderItem *models.OrderItem) error {
+	if _, ok := d.orders[orderItem.OrderID]; !ok {
+		return missingOrderError(orderItem.OrderID)
+	}
+
+	d.lastOrderItemID++
+	orderItem.ID = d.lastOrderItemID
+	orderItem.CreatedAt = d.now()
+	orderItem.UpdatedAt = orderItem.CreatedAt
+	d.orderItems[orderItem.ID] = copyOrderItem(orderItem)
+	return nil
+}
+
+// deleteOrderItems removes all items of the order, the caller must hold the write lock
+func (d *Database) deleteOrderItems(orderID models.OrderID) {
+	for id, item := range d.orderItems {
+		if item.OrderID == orderID {
+			delete(d.orderItems, id)
+		}
+	}
+}
+
+// markOrderItemsDeleted sets DeletedAt of all items of the order which are not deleted yet
+// or, when deletedAt is nil, clears it. The caller must hold the write lock.
+func (d *Database) markOrderItemsDeleted(orderID models.OrderID, deletedAt *time.Time) {
+	updatedAt := d.now()
+	if deletedAt != nil {
+		updatedAt = *deletedAt
+	}
+	for _, item := range d.orderItems {
+		if item.OrderID == orderID && (deletedAt == nil) != (item.DeletedAt == nil) {
+			item.DeletedAt = deletedAt
+			item.UpdatedAt = updatedAt
+		}
+	}
+}
+
+// updateOrderItem replaces a stored order item, the caller must hold the write lock
+func (d *Database) updateOrderItem(orderItem *models.OrderItem) error {
+	stored, ok := d.orderItems[orderItem.ID]
+	if !ok {
+		return notFoundError(errors.Errorf("order item %d", orderItem.ID))
+	}
+	if _, ok := d.orders[orderItem.OrderID]; !ok {
+		return missingOrderError(orderItem.OrderID)
+	}
+
+	orderItem.UpdatedAt = d.now()
+	updated := copyOrderItem(orderItem)
+	updated.CreatedAt = stored.CreatedAt
+	updated.DeletedAt = stored.DeletedAt
+	d.orderItems[orderItem.ID] = updated
+	return nil
+}
+
+// closer marks a repository closed, embedded by every repository
+type closer struct {
+	closed int32
+}
+
+// Close makes later calls fail with repositories.ErrClosed
+func (c *closer) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+// check rejects calls to a closed repository and calls with a done context
+func (c *closer) check(ctx context.Context) error {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return repositories.ErrClosed
+	}
+	return checkContext(ctx)
+}
+
+// checkContext reports a cancelled or expired context the way
+// database/sql does before running a query
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "context error")
+	}
+	return nil
+}
+
+// validateMoney mirrors CHECK(... >= 0) constraints of the database schema
+func validateMoney(money models.Money) error {
+	if err := money.Validate(); err != nil {
+		return validationError(err)
+	}
+	if money.IsNegative() {
+		return validationError(errors.Errorf("negative value %s", money))
+	}
+	return nil
+}
+
+// validateNewOrder defaults the status of an order to be inserted and validates the order,
+// which starts as a draft and reaches other statuses by TransitionStatus
+func validateNewOrder(order *models.Order) error {
+	if order.Status == "" {
+		order.Status = models.OrderStatusDraft
+	}
+	if err := order.Status.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate status error")
+	}
+	if order.Status != models.OrderStatusDraft {
+		err := errors.Wrapf(models.ErrIllegalTransition, "new order is %s, not %s", order.Status, models.OrderStatusDraft)
+		return errors.Wrap(validationError(err), "validate status error")
+	}
+	return validateOrder(order)
+}
+
+func validateOrder(order *models.Order) error {
+	if err := validateMoney(order.Amount); err != nil {
+		return errors.Wrap(err, "validate amount error")
+	}
+	for _, item := range order.Items {
+		if err := validateMoney(item.Price); err != nil {
+			return errors.Wrap(err, "validate order item price error")
+		}
+	}
+	return nil
+}
+
+func copyOrder(order *models.Order) *models.Order {
+	orderCopy := *order
+	orderCopy.Items = nil
+	return &orderCopy
+}
+
+// sameItem reports whether an item matches the stored one in everything but timestamps
+func sameItem(stored, item *models.OrderItem) bool {
+	return stored.OrderID == item.OrderID && stored.ProductID == item.ProductID &&
+		stored.Quantity == item.Quantity && stored.Price == item.Price
+}
+
+func copyOrderItem(orderItem *models.OrderItem) *models.OrderItem {
+	orderItemCopy := *orderItem
+	return &orderItemCopy
+}
+
+func notFoundError(err error) error {
+	return &repositories.Error{Kind: repositories.ErrNotFound, Err: err}
+}
+
+func validationError(err error) error {
+	return &repositories.Error{Kind: repositories.ErrValidation, Err: err}
+}
+
+func staleVersionError(orderID models.OrderID, current, expected int64) error {
+	return &repositories.Error{
+		Kind: repositories.ErrStaleVersion,
+		Err:  errors.Errorf("order %d is at version %d, not %d", orderID, current, expected),
+	}
+}
+
+func duplicatePublicIDError(publicID string) error {
+	return &repositories.Error{
+		Kind:   repositories.ErrConflict,
+		Entity: "order",
+		Key:    publicID,
+		Err:    errors.Errorf("public id %q is taken", publicID),
+	}
+}
+
+func idempotencyKeyReusedError(key string) error {
+	return &repositories.Error{
+		Kind: repositories.ErrIdempotencyKeyReused,
+		Key:  key,
+		Err:  errors.Errorf("idempotency key %q was used for another order", key),
+	}
+}
+
+func missingOrderError(orderID models.OrderID) error {
+	return &repositories.Error{
+		Kind:   repositories.ErrInvalidReference,
+		Entity: "order",
+		Key:    strconv.Itoa(int(orderID)),
+		Err:    errors.Errorf("order %d does not exist", orderID),
+	}
+}
@@ -0,0 +1,376 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+func NewOrderRepository(db *Database) repositories.OrderRepository {
+	return &order{
+		db: db,
+	}
+}
+
+type order struct {
+	closer
+	db *Database
+}
+
+func (o *order) GetByID(ctx context.Context, orderID models.OrderID, options ...repositories.QueryOption) (*models.Order, error) {
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	includeDeleted := repositories.NewQueryOptions(options...).IncludeDeleted
+	stored, ok := db.orders[orderID]
+	if !ok || !includeDeleted && stored.DeletedAt != nil {
+		return nil, notFoundError(errors.Errorf("order %d", orderID))
+	}
+
+	order := copyOrder(stored)
+	order.Items = db.itemsByOrderID(orderID, includeDeleted)
+
+	return order, nil
+}
+
+func (o *order) GetByPublicID(ctx context.Context, publicID string, options ...repositories.QueryOption) (*models.Order, error) {
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	includeDeleted := repositories.NewQueryOptions(options...).IncludeDeleted
+	stored, ok := db.orderByPublicID(publicID)
+	if !ok || !includeDeleted && stored.DeletedAt != nil {
+		return nil, notFoundError(errors.Errorf("order %q", publicID))
+	}
+
+	order := copyOrder(stored)
+	order.Items = db.itemsByOrderID(order.ID, includeDeleted)
+
+	return order, nil
+}
+
+func (o *order) GetByIDs(ctx context.Context, orderIDs []models.OrderID, options ...repositories.QueryOption) ([]*models.Order, error) {
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	includeDeleted := repositories.NewQueryOptions(options...).IncludeDeleted
+	orders := []*models.Order{}
+	seen := make(map[models.OrderID]bool, len(orderIDs))
+	for _, orderID := range orderIDs {
+		stored, ok := db.orders[orderID]
+		if !ok || seen[orderID] || !includeDeleted && stored.DeletedAt != nil {
+			continue
+		}
+		seen[orderID] = true
+
+		order := copyOrder(stored)
+		order.Items = db.itemsByOrderID(orderID, includeDeleted)
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// Save stores the order and its items at once, nothing is stored when any of them is invalid
+func (o *order) Save(ctx context.Context, order *models.Order) error {
+	if err := validateNewOrder(order); err != nil {
+		return err
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	publicID, err := db.newPublicID(order.PublicID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	return db.insertOrder(order, publicID)
+}
+
+// SaveIdempotent takes the key and saves the order under the same lock
+func (o *order) SaveIdempotent(ctx context.Context, key string, order *models.Order) error {
+	if err := validateNewOrder(order); err != nil {
+		return err
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	publicID, err := db.newPublicID(order.PublicID)
+	if err != nil {
+		return err
+	}
+	hash := repositories.OrderRequestHash(order)
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	now := db.now()
+	if taken, ok := db.idempotencyKeys[key]; ok && !taken.createdAt.Before(now.Add(-db.idempotencyTTL)) {
+		if taken.requestHash != hash {
+			return idempotencyKeyReusedError(key)
+		}
+		*order = *copyOrder(db.orders[taken.orderID])
+		order.Items = db.itemsByOrderID(taken.orderID, true)
+		return nil
+	}
+
+	if err := db.insertOrder(order, publicID); err != nil {
+		return err
+	}
+	db.idempotencyKeys[key] = idempotencyKey{requestHash: hash, orderID: order.ID, createdAt: now}
+
+	return nil
+}
+
+func (o *order) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	if err := o.check(ctx); err != nil {
+		return 0, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := db.lock(); err != nil {
+		return 0, err
+	}
+	defer db.mu.Unlock()
+
+	var purged int64
+	expiry := db.now().Add(-db.idempotencyTTL)
+	for key, taken := range db.idempotencyKeys {
+		if taken.createdAt.Before(expiry) {
+			delete(db.idempotencyKeys, key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// Update reconciles stored items the same way the postgresql backend does:
+// items without ID are inserted, changed ones are updated and missing ones are deleted
+func (o *order) Update(ctx context.Context, order *models.Order) error {
+	if err := validateOrder(order); err != nil {
+		return err
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	stored, ok := db.live(order.ID)
+	if !ok {
+		return errors.Wrap(notFoundError(errors.Errorf("order %d", order.ID)), "update order error")
+	}
+	if stored.Version != order.Version {
+		return errors.Wrap(staleVersionError(order.ID, stored.Version, order.Version), "update order error")
+	}
+
+	kept := make(map[int64]bool, len(order.Items))
+	for _, item := range order.Items {
+		if item.ID == 0 {
+			continue
+		}
+		storedItem, ok := db.orderItems[item.ID]
+		if !ok || storedItem.OrderID != order.ID {
+			return validationError(errors.Errorf("order item %d does not belong to order %d", item.ID, order.ID))
+		}
+		kept[item.ID] = true
+	}
+
+	updated := copyOrder(order)
+	updated.PublicID = stored.PublicID
+	updated.Status = stored.Status
+	updated.DeletedAt = stored.DeletedAt
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = db.now()
+	updated.Version = stored.Version + 1
+	db.orders[order.ID] = updated
+
+	for _, item := range db.itemsByOrderID(order.ID, false) {
+		if !kept[item.ID] {
+			delete(db.orderItems, item.ID)
+		}
+	}
+	for _, item := range order.Items {
+		item.OrderID = order.ID
+		if item.ID == 0 {
+			if err := db.insertOrderItem(item); err != nil {
+				return errors.Wrap(err, "save order item error")
+			}
+			continue
+		}
+		if sameItem(db.orderItems[item.ID], item) {
+			continue
+		}
+		if err := db.updateOrderItem(item); err != nil {
+			return errors.Wrap(err, "update order item error")
+		}
+	}
+	order.Version, order.UpdatedAt = updated.Version, updated.UpdatedAt
+
+	return nil
+}
+
+func (o *order) Delete(ctx context.Context, orderID models.OrderID, version int64) error {
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	stored, ok := db.live(orderID)
+	if !ok {
+		return errors.Wrap(notFoundError(errors.Errorf("order %d", orderID)), "delete order error")
+	}
+	if stored.Version != version {
+		return errors.Wrap(staleVersionError(orderID, stored.Version, version), "delete order error")
+	}
+
+	db.deleteOrder(orderID)
+
+	return nil
+}
+
+func (o *order) SoftDelete(ctx context.Context, orderID models.OrderID, version int64) error {
+	return o.markDeleted(ctx, orderID, version, true)
+}
+
+func (o *order) Restore(ctx context.Context, orderID models.OrderID, version int64) error {
+	return o.markDeleted(ctx, orderID, version, false)
+}
+
+// markDeleted soft deletes or restores the order together with its items
+func (o *order) markDeleted(ctx context.Context, orderID models.OrderID, version int64, deleted bool) error {
+	action := "restore order error"
+	if deleted {
+		action = "soft delete order error"
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	stored, ok := db.orders[orderID]
+	if !ok || (stored.DeletedAt == nil) != deleted {
+		return errors.Wrap(notFoundError(errors.Errorf("order %d", orderID)), action)
+	}
+	if stored.Version != version {
+		return errors.Wrap(staleVersionError(orderID, stored.Version, version), action)
+	}
+
+	now := db.now()
+	var deletedAt *time.Time
+	if deleted {
+		deletedAt = &now
+	}
+	stored.DeletedAt = deletedAt
+	stored.UpdatedAt = now
+	stored.Version++
+	db.markOrderItemsDeleted(orderID, deletedAt)
+
+	return nil
+}
+
+func (o *order) TransitionStatus(ctx context.Context, orderID models.OrderID, from, to models.OrderStatus) error {
+	if err := from.ValidateTransition(to); err != nil {
+		return errors.Wrap(validationError(err), "validate transition error")
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	stored, ok := db.live(orderID)
+	if !ok {
+		return notFoundError(errors.Errorf("order %d", orderID))
+	}
+	if stored.Status != from {
+		return &repositories.Error{
+			Kind: repositories.ErrStaleStatus,
+			Err:  errors.Errorf("order %d is %s, not %s", orderID, stored.Status, from),
+		}
+	}
+	stored.Status = to
+	stored.UpdatedAt = db.now()
+	stored.Version++
+
+	return nil
+}
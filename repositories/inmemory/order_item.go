@@ -0,0 +1,200 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+func NewOrderItemRepository(db *Database) repositories.OrderItemRepository {
+	return &orderItem{
+		db: db,
+	}
+}
+
+type orderItem struct {
+	closer
+	db *Database
+}
+
+func (o *orderItem) GetByOrderID(ctx context.Context, orderID models.OrderID, options ...repositories.QueryOption) ([]*models.OrderItem, error) {
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.itemsByOrderID(orderID, repositories.NewQueryOptions(options...).IncludeDeleted), nil
+}
+
+func (o *orderItem) GetByOrderIDs(ctx context.Context, orderIDs []models.OrderID, options ...repositories.QueryOption) (map[models.OrderID][]*models.OrderItem, error) {
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	includeDeleted := repositories.NewQueryOptions(options...).IncludeDeleted
+	orderItems := make(map[models.OrderID][]*models.OrderItem, len(orderIDs))
+	for _, orderID := range orderIDs {
+		orderItems[orderID] = db.itemsByOrderID(orderID, includeDeleted)
+	}
+
+	return orderItems, nil
+}
+
+func (o *orderItem) Save(ctx context.Context, orderItem *models.OrderItem) error {
+	if err := validateMoney(orderItem.Price); err != nil {
+		return errors.Wrap(err, "validate price")
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	return db.insertOrderItem(orderItem)
+}
+
+// SaveAll stores all items or, when any of them is invalid, none
+func (o *orderItem) SaveAll(ctx context.Context, orderItems []*models.OrderItem) error {
+	for i, orderItem := range orderItems {
+		if err := validateMoney(orderItem.Price); err != nil {
+			return errors.Wrapf(err, "validate price of item %d", i)
+		}
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	for _, orderItem := range orderItems {
+		if _, ok := db.orders[orderItem.OrderID]; !ok {
+			return missingOrderError(orderItem.OrderID)
+		}
+	}
+	for _, orderItem := range orderItems {
+		if err := db.insertOrderItem(orderItem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *orderItem) Update(ctx context.Context, orderItem *models.OrderItem) error {
+	if err := validateMoney(orderItem.Price); err != nil {
+		return errors.Wrap(err, "validate price")
+	}
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	return db.updateOrderItem(orderItem)
+}
+
+func (o *orderItem) Delete(ctx context.Context, orderItemID int64) error {
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	if _, ok := db.orderItems[orderItemID]; !ok {
+		return notFoundError(errors.Errorf("order item %d", orderItemID))
+	}
+	delete(db.orderItems, orderItemID)
+
+	return nil
+}
+
+func (o *orderItem) DeleteByOrderID(ctx context.Context, orderID models.OrderID) error {
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	db.deleteOrderItems(orderID)
+
+	return nil
+}
+
+func (o *orderItem) SoftDeleteByOrderID(ctx context.Context, orderID models.OrderID, deletedAt time.Time) error {
+	return o.markDeleted(ctx, orderID, &deletedAt)
+}
+
+func (o *orderItem) RestoreByOrderID(ctx context.Context, orderID models.OrderID) error {
+	return o.markDeleted(ctx, orderID, nil)
+}
+
+func (o *orderItem) markDeleted(ctx context.Context, orderID models.OrderID, deletedAt *time.Time) error {
+	if err := o.check(ctx); err != nil {
+		return err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.lock(); err != nil {
+		return err
+	}
+	defer db.mu.Unlock()
+
+	db.markOrderItemsDeleted(orderID, deletedAt)
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package inmemory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+func (o *order) List(ctx context.Context, filter repositories.OrderFilter, page repositories.Page, options ...repositories.QueryOption) (*repositories.OrderPage, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return nil, errors.Wrap(validationError(err), "validate page error")
+	}
+
+	var after *repositories.OrderCursor
+	if page.Cursor != "" {
+		cursor, err := repositories.DecodeOrderCursor(page.Cursor, page.Sort)
+		if err != nil {
+			return nil, errors.Wrap(validationError(err), "decode cursor error")
+		}
+		after = &cursor
+	}
+	if err := o.check(ctx); err != nil {
+		return nil, err
+	}
+	db, err := o.db.in(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	includeDeleted := repositories.NewQueryOptions(options...).IncludeDeleted
+	orders := []*models.Order{}
+	for _, order := range db.orders {
+		if (includeDeleted || order.DeletedAt == nil) && matches(order, filter) && (after == nil || follows(order, *after)) {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return less(orders[i], orders[j], page.Sort)
+	})
+
+	result := &repositories.OrderPage{Orders: orders}
+	if len(orders) > page.Limit {
+		result.Orders = orders[:page.Limit]
+		result.NextCursor = repositories.NewOrderCursor(page.Sort, result.Orders[page.Limit-1]).Encode()
+	}
+
+	for i, stored := range result.Orders {
+		order := copyOrder(stored)
+		order.Items = db.itemsByOrderID(order.ID, includeDeleted)
+		result.Orders[i] = order
+	}
+
+	return result, nil
+}
+
+func matches(order *models.Order, filter repositories.OrderFilter) bool {
+	switch {
+	case filter.CustomerID != 0 && order.CustomerID != filter.CustomerID,
+		filter.Currency != "" && order.Amount.Currency != filter.Currency,
+		filter.MinAmount != nil && order.Amount.Value < *filter.MinAmount,
+		filter.MaxAmount != nil && order.Amount.Value > *filter.MaxAmount,
+		!filter.CreatedFrom.IsZero() && order.CreatedAt.Before(filter.CreatedFrom),
+		!filter.CreatedTo.IsZero() && !order.CreatedAt.Before(filter.CreatedTo):
+		return false
+	}
+
+	if len(filter.Statuses) == 0 {
+		return true
+	}
+	for _, status := range filter.Statuses {
+		if order.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares listing positions by the sort key and then by order ID,
+// the result is reversed for descending sort orders
+func compare(a, b repositories.OrderCursor) int {
+	c := a.Amount.Cmp(b.Amount)
+	if a.Sort != repositories.SortByAmountAsc && a.Sort != repositories.SortByAmountDesc {
+		switch {
+		case a.CreatedAt.Before(b.CreatedAt):
+			c = -1
+		case a.CreatedAt.After(b.CreatedAt):
+			c = 1
+		default:
+			c = 0
+		}
+	}
+	if c == 0 {
+		switch {
+		case a.OrderID < b.OrderID:
+			c = -1
+		case a.OrderID > b.OrderID:
+			c = 1
+		}
+	}
+	if a.Sort.Descending() {
+		c = -c
+	}
+	return c
+}
+
+func less(a, b *models.Order, sortKey repositories.OrderSort) bool {
+	return compare(repositories.NewOrderCursor(sortKey, a), repositories.NewOrderCursor(sortKey, b)) < 0
+}
+
+// follows reports whether the order comes after the cursor in the listing
+func follows(order *models.Order, cursor repositories.OrderCursor) bool {
+	return compare(repositories.NewOrderCursor(cursor.Sort, order), cursor) > 0
+}
@@ -0,0 +1,349 @@
+// +build unit
+
+package inmemory
+
+import (
+	"context"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/netology/dao-pattern/repositories/repositorytest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newOrder() *models.Order {
+	return &models.Order{
+		CustomerID: 1,
+		Amount:     models.Money{Value: models.MustDecimalFromInt(20), Currency: models.USD},
+		Items: []*models.OrderItem{
+			{
+				ProductID: 1,
+				Quantity:  1,
+				Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+			},
+			{
+				ProductID: 2,
+				Quantity:  1,
+				Price:     models.Money{Value: models.MustDecimalFromInt(12), Currency: models.USD},
+			},
+		},
+	}
+}
+
+func TestOrderRepositorySuite(t *testing.T) {
+	repositorytest.RunOrderRepositorySuite(t, func(t *testing.T) repositorytest.OrderBackend {
+		db := NewDatabase()
+		return repositorytest.OrderBackend{
+			Orders:     NewOrderRepository(db),
+			Tx:         NewTxManager(db),
+			CustomerID: 1,
+			ProductIDs: []models.ProductID{1, 2},
+		}
+	})
+}
+
+func TestOrder_Save(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		orderRepository := NewOrderRepository(NewDatabase())
+		orderEntity := newOrder()
+		err := orderRepository.Save(ctx, orderEntity)
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(1), orderEntity.ID)
+		require.Equal(t, int64(2), orderEntity.Items[1].ID)
+		require.Equal(t, orderEntity.ID, orderEntity.Items[1].OrderID)
+
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, orderEntity, order)
+	})
+
+	t.Run("stored order is isolated from callers", func(t *testing.T) {
+		orderRepository := NewOrderRepository(NewDatabase())
+		orderEntity := newOrder()
+		require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+		orderEntity.Amount.Value = models.MustDecimalFromInt(1)
+		orderEntity.Items[0].Quantity = 100
+
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.MustDecimalFromInt(20), order.Amount.Value)
+		require.Equal(t, 1, order.Items[0].Quantity)
+
+		order.Items[0].Quantity = 50
+		order, err = orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, order.Items[0].Quantity)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("invalid item stores nothing", func(t *testing.T) {
+			db := NewDatabase()
+			orderRepository := NewOrderRepository(db)
+			orderEntity := newOrder()
+			orderEntity.Items[1].Price.Value = models.MustDecimalFromInt(-12)
+
+			err := orderRepository.Save(ctx, orderEntity)
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+			require.Empty(t, db.orders)
+			require.Empty(t, db.orderItems)
+		})
+
+		t.Run("context is cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			orderRepository := NewOrderRepository(NewDatabase())
+			err := orderRepository.Save(ctx, newOrder())
+			require.Equal(t, context.Canceled, errors.Cause(err))
+		})
+	})
+
+	t.Run("concurrent saves get unique ids", func(t *testing.T) {
+		orderRepository := NewOrderRepository(NewDatabase())
+
+		const n = 50
+		ids := make(chan models.OrderID, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				orderEntity := newOrder()
+				if err := orderRepository.Save(ctx, orderEntity); err != nil {
+					t.Error(err)
+				}
+				ids <- orderEntity.ID
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := map[models.OrderID]bool{}
+		for id := range ids {
+			require.False(t, seen[id])
+			seen[id] = true
+		}
+		require.Len(t, seen, n)
+	})
+}
+
+func TestOrder_Update(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		orderRepository := NewOrderRepository(NewDatabase())
+		orderEntity := newOrder()
+		require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+		orderEntity.Amount.Value = models.MustDecimalFromInt(35)
+		orderEntity.Items[1].Quantity = 2
+		orderEntity.Items = append(orderEntity.Items[1:], &models.OrderItem{
+			ProductID: 3,
+			Quantity:  1,
+			Price:     models.Money{Value: models.MustDecimalFromInt(11), Currency: models.USD},
+		})
+		err := orderRepository.Update(ctx, orderEntity)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), orderEntity.Items[1].ID)
+		require.Equal(t, int64(2), orderEntity.Version)
+
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, orderEntity, order)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("order does not exist", func(t *testing.T) {
+			orderRepository := NewOrderRepository(NewDatabase())
+			orderEntity := newOrder()
+			orderEntity.ID = 7
+
+			err := orderRepository.Update(ctx, orderEntity)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+
+		t.Run("item of another order", func(t *testing.T) {
+			orderRepository := NewOrderRepository(NewDatabase())
+			first, second := newOrder(), newOrder()
+			require.NoError(t, orderRepository.Save(ctx, first))
+			require.NoError(t, orderRepository.Save(ctx, second))
+
+			second.Amount.Value = models.MustDecimalFromInt(1)
+			second.Items = append(second.Items, first.Items[0])
+			err := orderRepository.Update(ctx, second)
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+
+			order, err := orderRepository.GetByID(ctx, second.ID)
+			require.NoError(t, err)
+			require.Equal(t, models.MustDecimalFromInt(20), order.Amount.Value)
+		})
+	})
+}
+
+func TestOrder_Delete(t *testing.T) {
+	ctx := context.Background()
+	db := NewDatabase()
+	orderRepository := NewOrderRepository(db)
+
+	orderEntity := newOrder()
+	require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+	err := orderRepository.Delete(ctx, orderEntity.ID, 2)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+
+	err = orderRepository.Delete(ctx, orderEntity.ID, orderEntity.Version)
+	require.NoError(t, err)
+	require.Empty(t, db.orderItems)
+
+	_, err = orderRepository.GetByID(ctx, orderEntity.ID)
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+
+	err = orderRepository.Delete(ctx, orderEntity.ID, orderEntity.Version)
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+}
+
+func TestOrder_Timestamps(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	orderRepository := NewOrderRepository(NewDatabase(WithClock(repositories.ClockFunc(func() time.Time { return now }))))
+
+	orderEntity := newOrder()
+	require.NoError(t, orderRepository.Save(ctx, orderEntity))
+	require.Equal(t, now, orderEntity.CreatedAt)
+	require.Equal(t, now, orderEntity.UpdatedAt)
+	require.Equal(t, now, orderEntity.Items[0].CreatedAt)
+
+	createdAt := now
+	now = now.Add(time.Hour)
+	orderEntity.Items[1].Quantity = 2
+	require.NoError(t, orderRepository.Update(ctx, orderEntity))
+
+	order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, createdAt, order.CreatedAt)
+	require.Equal(t, now, order.UpdatedAt)
+	require.Equal(t, createdAt, order.Items[0].UpdatedAt)
+	require.Equal(t, createdAt, order.Items[1].CreatedAt)
+	require.Equal(t, now, order.Items[1].UpdatedAt)
+}
+
+func TestOrder_PublicID(t *testing.T) {
+	ctx := context.Background()
+	generator := repositories.IDGeneratorFunc(func() (string, error) {
+		return "01ARYZ6S41TSV4RRFFQ69G5FAV", nil
+	})
+	orderRepository := NewOrderRepository(NewDatabase(WithIDGenerator(generator)))
+
+	orderEntity := newOrder()
+	require.NoError(t, orderRepository.Save(ctx, orderEntity))
+	require.Equal(t, "01ARYZ6S41TSV4RRFFQ69G5FAV", orderEntity.PublicID)
+
+	order, err := orderRepository.GetByPublicID(ctx, "01ARYZ6S41TSV4RRFFQ69G5FAV")
+	require.NoError(t, err)
+	require.Equal(t, orderEntity, order)
+
+	err = orderRepository.Save(ctx, newOrder())
+	require.True(t, errors.Is(err, repositories.ErrConflict))
+}
+
+func TestOrder_IdempotencyKeyExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	db := NewDatabase(WithIdempotencyKeyTTL(time.Hour), WithClock(repositories.ClockFunc(func() time.Time { return now })))
+	orderRepository := NewOrderRepository(db)
+
+	first := newOrder()
+	require.NoError(t, orderRepository.SaveIdempotent(ctx, "checkout-1", first))
+
+	now = now.Add(time.Hour + time.Minute)
+	second := newOrder()
+	second.Items = second.Items[:1]
+	require.NoError(t, orderRepository.SaveIdempotent(ctx, "checkout-2", second))
+
+	purged, err := orderRepository.PurgeIdempotencyKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	// the expired key is free to create another order
+	third := newOrder()
+	third.Items = third.Items[:1]
+	require.NoError(t, orderRepository.SaveIdempotent(ctx, "checkout-1", third))
+	require.NotEqual(t, first.ID, third.ID)
+}
+
+func TestOrderItem(t *testing.T) {
+	ctx := context.Background()
+	db := NewDatabase()
+	orderRepository := NewOrderRepository(db)
+	orderItemRepository := NewOrderItemRepository(db)
+
+	orderEntity := newOrder()
+	require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+	t.Run("save, update and delete", func(t *testing.T) {
+		orderItem := &models.OrderItem{
+			OrderID:   orderEntity.ID,
+			ProductID: 3,
+			Quantity:  1,
+			Price:     models.Money{Value: models.MustDecimalFromInt(5), Currency: models.USD},
+		}
+		require.NoError(t, orderItemRepository.Save(ctx, orderItem))
+
+		orderItem.Quantity = 3
+		require.NoError(t, orderItemRepository.Update(ctx, orderItem))
+
+		orderItems, err := orderItemRepository.GetByOrderID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Len(t, orderItems, 3)
+		require.Equal(t, orderItem, orderItems[2])
+
+		require.NoError(t, orderItemRepository.Delete(ctx, orderItem.ID))
+		err = orderItemRepository.Delete(ctx, orderItem.ID)
+		require.True(t, errors.Is(err, repositories.ErrNotFound))
+	})
+
+	t.Run("missing order", func(t *testing.T) {
+		err := orderItemRepository.Save(ctx, &models.OrderItem{OrderID: 42, Price: models.Money{Currency: models.USD}})
+		require.True(t, errors.Is(err, repositories.ErrInvalidReference))
+
+		var repositoryError *repositories.Error
+		require.True(t, errors.As(err, &repositoryError))
+		require.Equal(t, "order", repositoryError.Entity)
+		require.Equal(t, "42", repositoryError.Key)
+	})
+
+	t.Run("save all", func(t *testing.T) {
+		items := []*models.OrderItem{
+			{OrderID: orderEntity.ID, ProductID: 1, Quantity: 1, Price: models.Money{Currency: models.USD}},
+			{OrderID: orderEntity.ID, ProductID: 2, Quantity: 1, Price: models.Money{Currency: models.USD}},
+		}
+		require.NoError(t, orderItemRepository.SaveAll(ctx, items))
+		require.NotZero(t, items[0].ID)
+		require.NotZero(t, items[1].ID)
+
+		require.NoError(t, orderItemRepository.DeleteByOrderID(ctx, orderEntity.ID))
+		stored, err := orderItemRepository.GetByOrderID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Empty(t, stored)
+	})
+
+	t.Run("save all stores nothing with a missing order", func(t *testing.T) {
+		items := []*models.OrderItem{
+			{OrderID: orderEntity.ID, ProductID: 1, Quantity: 1, Price: models.Money{Currency: models.USD}},
+			{OrderID: 42, ProductID: 2, Quantity: 1, Price: models.Money{Currency: models.USD}},
+		}
+		err := orderItemRepository.SaveAll(ctx, items)
+		require.True(t, errors.Is(err, repositories.ErrInvalidReference))
+		require.Zero(t, items[0].ID)
+
+		stored, err := orderItemRepository.GetByOrderID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Empty(t, stored)
+	})
+}
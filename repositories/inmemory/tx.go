@@ -0,0 +1,147 @@
+package inmemory
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// txMaxAttempts matches postgresql.DefaultRetryPolicy, so a transaction
+// which loses to a concurrent write is run again as it is on PostgreSQL
+const txMaxAttempts = 3
+
+// NewTxManager runs transactions on a private copy of db. A commit publishes
+// the copy and fails with repositories.ErrSerialization when db was written
+// to since the transaction began, so every isolation level is serializable.
+// Such a transaction is run again up to txMaxAttempts times in all, fn must
+// not have side effects outside of the database.
+func NewTxManager(db *Database) repositories.TxManager {
+	return &txManager{
+		db: db,
+	}
+}
+
+type txManager struct {
+	db *Database
+}
+
+// txKey is the context key of the transaction state
+type txKey struct{}
+
+// txState is a transaction carried by a context
+type txState struct {
+	base    *Database
+	db      *Database
+	version uint64
+	options repositories.TxOptions
+	done    int32
+}
+
+// in returns the transaction copy of d carried by ctx or d itself,
+// a finished transaction fails with sql.ErrTxDone like a *sql.Tx does
+func (d *Database) in(ctx context.Context) (*Database, error) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok || state.base != d {
+		return d, nil
+	}
+	if atomic.LoadInt32(&state.done) != 0 {
+		return nil, sql.ErrTxDone
+	}
+	return state.db, nil
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error, options ...repositories.TxOption) error {
+	txOptions := repositories.NewTxOptions(options...)
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.base == m.db {
+		return state.savepoint(ctx, txOptions, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.run(ctx, txOptions, fn)
+		if err == nil || attempt >= txMaxAttempts || !errors.Is(err, repositories.ErrSerialization) {
+			return err
+		}
+	}
+}
+
+// run runs fn in a new transaction, committed when fn succeeds
+func (m *txManager) run(ctx context.Context, txOptions repositories.TxOptions, fn func(ctx context.Context) error) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	m.db.mu.RLock()
+	state := &txState{
+		base:    m.db,
+		db:      m.db.clone(),
+		version: m.db.version,
+		options: txOptions,
+	}
+	m.db.mu.RUnlock()
+	state.db.readOnly = txOptions.ReadOnly
+	defer atomic.StoreInt32(&state.done, 1)
+
+	// a rolled back or panicking transaction simply drops its copy
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return err
+	}
+
+	return state.commit()
+}
+
+// savepoint runs fn on the transaction copy and restores it when fn fails
+func (s *txState) savepoint(ctx context.Context, options repositories.TxOptions, fn func(ctx context.Context) error) error {
+	if options != (repositories.TxOptions{}) && options != s.options {
+		return errors.Errorf("options %+v of a nested transaction differ from %+v of the outer one", options, s.options)
+	}
+
+	s.db.mu.RLock()
+	snapshot := s.db.clone()
+	s.db.mu.RUnlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.db.restore(snapshot)
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		s.db.restore(snapshot)
+		return err
+	}
+
+	return nil
+}
+
+// commit replaces the contents of the base database with the transaction copy
+func (s *txState) commit() error {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	if s.db.version == s.version {
+		// nothing was written
+		return nil
+	}
+
+	s.base.mu.Lock()
+	defer s.base.mu.Unlock()
+	if s.base.version != s.version {
+		return &repositories.Error{
+			Kind: repositories.ErrSerialization,
+			Err:  errors.New("database was changed by a concurrent write"),
+		}
+	}
+
+	s.base.orders = s.db.orders
+	s.base.orderItems = s.db.orderItems
+	s.base.idempotencyKeys = s.db.idempotencyKeys
+	s.base.lastOrderID = s.db.lastOrderID
+	s.base.lastOrderItemID = s.db.lastOrderItemID
+	s.base.version++
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// +build unit
+
+package inmemory
+
+import (
+	"context"
+	"database/sql"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTxManager_WithinTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("concurrent write retries the transaction", func(t *testing.T) {
+		db := NewDatabase()
+		orderRepository := NewOrderRepository(db)
+
+		attempts := 0
+		err := NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := orderRepository.Save(ctx, newOrder()); err != nil {
+				return err
+			}
+			if attempts > 1 {
+				return nil
+			}
+			// written outside of the transaction
+			return orderRepository.Save(context.Background(), newOrder())
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+
+		page, err := orderRepository.List(ctx, repositories.OrderFilter{}, repositories.Page{})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 2)
+	})
+
+	t.Run("concurrent writes exhaust the attempts", func(t *testing.T) {
+		db := NewDatabase()
+		orderRepository := NewOrderRepository(db)
+
+		attempts := 0
+		err := NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := orderRepository.Save(ctx, newOrder()); err != nil {
+				return err
+			}
+			// written outside of the transaction
+			return orderRepository.Save(context.Background(), newOrder())
+		})
+		require.True(t, errors.Is(err, repositories.ErrSerialization), "%v", err)
+		require.Equal(t, txMaxAttempts, attempts)
+
+		// only the writes outside of the transaction are kept
+		page, err := orderRepository.List(ctx, repositories.OrderFilter{}, repositories.Page{})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, txMaxAttempts)
+	})
+
+	t.Run("finished transaction", func(t *testing.T) {
+		db := NewDatabase()
+		orderRepository := NewOrderRepository(db)
+
+		var txCtx context.Context
+		err := NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+			txCtx = ctx
+			return nil
+		})
+		require.NoError(t, err)
+
+		err = orderRepository.Save(txCtx, newOrder())
+		require.Equal(t, sql.ErrTxDone, err)
+	})
+}
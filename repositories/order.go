@@ -3,11 +3,54 @@
 package repositories
 
 import (
+	"context"
+
 	"github.com/netology/dao-pattern/models"
 )
 
-// OrderRepository is a repository
+// OrderRepository is a repository. Save stores new orders as drafts and
+// fails with ErrValidation for any other status, Update keeps the stored
+// status, it is changed only by TransitionStatus.
+//
+// Orders are locked optimistically: Save sets Version, every change of the
+// order bumps it and Update and Delete fail with ErrStaleVersion when
+// the stored version differs from the one the caller passes.
+//
+// Save keeps PublicID which the caller set and otherwise generates it with
+// the IDGenerator of the repository, the serial ID in decimal is the public ID
+// of an order saved without one. A caller may not set such a decimal PublicID,
+// Save fails with ErrValidation then.
+//
+// Soft deleted orders and their items are hidden: reads skip them unless
+// called with IncludeDeleted and writes treat them as missing.
 type OrderRepository interface {
-	GetByID(orderID models.OrderID) (*models.Order, error)
-	Save(order *models.Order) error
+	GetByID(ctx context.Context, orderID models.OrderID, options ...QueryOption) (*models.Order, error)
+	GetByPublicID(ctx context.Context, publicID string, options ...QueryOption) (*models.Order, error)
+	// GetByIDs loads many orders with their items at once. Orders come in
+	// the requested order, missing and repeated IDs are skipped.
+	GetByIDs(ctx context.Context, orderIDs []models.OrderID, options ...QueryOption) ([]*models.Order, error)
+	Save(ctx context.Context, order *models.Order) error
+	// SaveIdempotent saves the order once per key: the key is stored in the same
+	// transaction, and a replay of it fills order with the one created first.
+	// A replay with a different order fails with ErrIdempotencyKeyReused.
+	// Keys expire after the TTL of the repository, DefaultIdempotencyKeyTTL.
+	SaveIdempotent(ctx context.Context, key string, order *models.Order) error
+	// PurgeIdempotencyKeys deletes expired idempotency keys and returns their number
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+	// Update stores the order if order.Version is current and sets the new version
+	Update(ctx context.Context, order *models.Order) error
+	Delete(ctx context.Context, orderID models.OrderID, version int64) error
+	// SoftDelete sets DeletedAt of the order and its items and bumps the version
+	SoftDelete(ctx context.Context, orderID models.OrderID, version int64) error
+	// Restore brings back a soft deleted order with its items and bumps the version,
+	// it fails with ErrNotFound for an order which is not deleted
+	Restore(ctx context.Context, orderID models.OrderID, version int64) error
+	// TransitionStatus moves an order from one status to another. It fails with
+	// ErrValidation for a transition the lifecycle does not allow and with
+	// ErrStaleStatus when the order is not in the from status anymore.
+	TransitionStatus(ctx context.Context, orderID models.OrderID, from, to models.OrderStatus) error
+	// List returns a page of orders matching the filter
+	List(ctx context.Context, filter OrderFilter, page Page, options ...QueryOption) (*OrderPage, error)
+	// Close releases prepared statements, later calls fail with ErrClosed
+	Close() error
 }
@@ -3,14 +3,31 @@
 package repositories
 
 import (
-	"database/sql"
+	"context"
+	"time"
 
 	"github.com/netology/dao-pattern/models"
 )
 
-// OrderItemRepository is a repository
+// OrderItemRepository is a repository. Like every repository it takes part
+// in a transaction carried by ctx, see TxManager.
 type OrderItemRepository interface {
-	GetByOrderID(orderID models.OrderID) ([]*models.OrderItem, error)
-	SaveWithTransaction(tx *sql.Tx, orderItem *models.OrderItem) error
-	Save(orderItem *models.OrderItem) error
+	GetByOrderID(ctx context.Context, orderID models.OrderID, options ...QueryOption) ([]*models.OrderItem, error)
+	// GetByOrderIDs loads items of many orders at once, every requested order
+	// is present in the result and has an empty slice when it has no items
+	GetByOrderIDs(ctx context.Context, orderIDs []models.OrderID, options ...QueryOption) (map[models.OrderID][]*models.OrderItem, error)
+	Save(ctx context.Context, orderItem *models.OrderItem) error
+	// SaveAll inserts many items at once and sets their IDs,
+	// either all of them are stored or none
+	SaveAll(ctx context.Context, orderItems []*models.OrderItem) error
+	Update(ctx context.Context, orderItem *models.OrderItem) error
+	Delete(ctx context.Context, orderItemID int64) error
+	DeleteByOrderID(ctx context.Context, orderID models.OrderID) error
+	// SoftDeleteByOrderID sets DeletedAt of the items of the order which are not deleted yet,
+	// the order passes its own deletion time
+	SoftDeleteByOrderID(ctx context.Context, orderID models.OrderID, deletedAt time.Time) error
+	// RestoreByOrderID clears DeletedAt of all items of the order
+	RestoreByOrderID(ctx context.Context, orderID models.OrderID) error
+	// Close releases prepared statements, later calls fail with ErrClosed
+	Close() error
 }
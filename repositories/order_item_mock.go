@@ -5,10 +5,11 @@
 package repositories
 
 import (
-	sql "database/sql"
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/netology/dao-pattern/models"
 	reflect "reflect"
+	time "time"
 )
 
 // MockOrderItemRepository is a mock of OrderItemRepository interface
@@ -35,44 +36,153 @@ func (m *MockOrderItemRepository) EXPECT() *MockOrderItemRepositoryMockRecorder
 }
 
 // GetByOrderID mocks base method
-func (m *MockOrderItemRepository) GetByOrderID(orderID models.OrderID) ([]*models.OrderItem, error) {
+func (m *MockOrderItemRepository) GetByOrderID(ctx context.Context, orderID models.OrderID, options ...QueryOption) ([]*models.OrderItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByOrderID", orderID)
+	varargs := []interface{}{ctx, orderID}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByOrderID", varargs...)
 	ret0, _ := ret[0].([]*models.OrderItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByOrderID indicates an expected call of GetByOrderID
-func (mr *MockOrderItemRepositoryMockRecorder) GetByOrderID(orderID interface{}) *gomock.Call {
+func (mr *MockOrderItemRepositoryMockRecorder) GetByOrderID(ctx, orderID interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByOrderID", reflect.TypeOf((*MockOrderItemRepository)(nil).GetByOrderID), orderID)
+	varargs := append([]interface{}{ctx, orderID}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByOrderID", reflect.TypeOf((*MockOrderItemRepository)(nil).GetByOrderID), varargs...)
 }
 
-// SaveWithTransaction mocks base method
-func (m *MockOrderItemRepository) SaveWithTransaction(tx *sql.Tx, orderItem *models.OrderItem) error {
+// GetByOrderIDs mocks base method
+func (m *MockOrderItemRepository) GetByOrderIDs(ctx context.Context, orderIDs []models.OrderID, options ...QueryOption) (map[models.OrderID][]*models.OrderItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveWithTransaction", tx, orderItem)
-	ret0, _ := ret[0].(error)
-	return ret0
+	varargs := []interface{}{ctx, orderIDs}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByOrderIDs", varargs...)
+	ret0, _ := ret[0].(map[models.OrderID][]*models.OrderItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// SaveWithTransaction indicates an expected call of SaveWithTransaction
-func (mr *MockOrderItemRepositoryMockRecorder) SaveWithTransaction(tx, orderItem interface{}) *gomock.Call {
+// GetByOrderIDs indicates an expected call of GetByOrderIDs
+func (mr *MockOrderItemRepositoryMockRecorder) GetByOrderIDs(ctx, orderIDs interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveWithTransaction", reflect.TypeOf((*MockOrderItemRepository)(nil).SaveWithTransaction), tx, orderItem)
+	varargs := append([]interface{}{ctx, orderIDs}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByOrderIDs", reflect.TypeOf((*MockOrderItemRepository)(nil).GetByOrderIDs), varargs...)
 }
 
 // Save mocks base method
-func (m *MockOrderItemRepository) Save(orderItem *models.OrderItem) error {
+func (m *MockOrderItemRepository) Save(ctx context.Context, orderItem *models.OrderItem) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", orderItem)
+	ret := m.ctrl.Call(m, "Save", ctx, orderItem)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save
-func (mr *MockOrderItemRepositoryMockRecorder) Save(orderItem interface{}) *gomock.Call {
+func (mr *MockOrderItemRepositoryMockRecorder) Save(ctx, orderItem interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOrderItemRepository)(nil).Save), ctx, orderItem)
+}
+
+// SaveAll mocks base method
+func (m *MockOrderItemRepository) SaveAll(ctx context.Context, orderItems []*models.OrderItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveAll", ctx, orderItems)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveAll indicates an expected call of SaveAll
+func (mr *MockOrderItemRepositoryMockRecorder) SaveAll(ctx, orderItems interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveAll", reflect.TypeOf((*MockOrderItemRepository)(nil).SaveAll), ctx, orderItems)
+}
+
+// Update mocks base method
+func (m *MockOrderItemRepository) Update(ctx context.Context, orderItem *models.OrderItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, orderItem)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update
+func (mr *MockOrderItemRepositoryMockRecorder) Update(ctx, orderItem interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockOrderItemRepository)(nil).Update), ctx, orderItem)
+}
+
+// Delete mocks base method
+func (m *MockOrderItemRepository) Delete(ctx context.Context, orderItemID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, orderItemID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockOrderItemRepositoryMockRecorder) Delete(ctx, orderItemID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrderItemRepository)(nil).Delete), ctx, orderItemID)
+}
+
+// DeleteByOrderID mocks base method
+func (m *MockOrderItemRepository) DeleteByOrderID(ctx context.Context, orderID models.OrderID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByOrderID", ctx, orderID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByOrderID indicates an expected call of DeleteByOrderID
+func (mr *MockOrderItemRepositoryMockRecorder) DeleteByOrderID(ctx, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByOrderID", reflect.TypeOf((*MockOrderItemRepository)(nil).DeleteByOrderID), ctx, orderID)
+}
+
+// SoftDeleteByOrderID mocks base method
+func (m *MockOrderItemRepository) SoftDeleteByOrderID(ctx context.Context, orderID models.OrderID, deletedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDeleteByOrderID", ctx, orderID, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDeleteByOrderID indicates an expected call of SoftDeleteByOrderID
+func (mr *MockOrderItemRepositoryMockRecorder) SoftDeleteByOrderID(ctx, orderID, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDeleteByOrderID", reflect.TypeOf((*MockOrderItemRepository)(nil).SoftDeleteByOrderID), ctx, orderID, deletedAt)
+}
+
+// RestoreByOrderID mocks base method
+func (m *MockOrderItemRepository) RestoreByOrderID(ctx context.Context, orderID models.OrderID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreByOrderID", ctx, orderID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreByOrderID indicates an expected call of RestoreByOrderID
+func (mr *MockOrderItemRepositoryMockRecorder) RestoreByOrderID(ctx, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreByOrderID", reflect.TypeOf((*MockOrderItemRepository)(nil).RestoreByOrderID), ctx, orderID)
+}
+
+// Close mocks base method
+func (m *MockOrderItemRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockOrderItemRepositoryMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOrderItemRepository)(nil).Save), orderItem)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockOrderItemRepository)(nil).Close))
 }
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+)
+
+// DefaultPageLimit is used when Page.Limit is not set
+const DefaultPageLimit = 20
+
+// MaxPageLimit caps Page.Limit
+const MaxPageLimit = 1000
+
+// OrderFilter narrows down listed orders, zero fields match everything
+type OrderFilter struct {
+	CustomerID models.CustomerID
+	Currency   models.Currency
+	// MinAmount and MaxAmount bound the amount inclusively
+	MinAmount *models.Decimal
+	MaxAmount *models.Decimal
+	Statuses  []models.OrderStatus
+	// CreatedFrom is inclusive and CreatedTo is exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// OrderSort is a sort order of listed orders, ties are broken by order ID
+type OrderSort string
+
+// Supported sort orders
+const (
+	SortByCreatedAtDesc OrderSort = "-created_at"
+	SortByCreatedAtAsc  OrderSort = "created_at"
+	SortByAmountDesc    OrderSort = "-amount"
+	SortByAmountAsc     OrderSort = "amount"
+)
+
+// Validate checks that the sort order is supported
+func (s OrderSort) Validate() error {
+	switch s {
+	case SortByCreatedAtDesc, SortByCreatedAtAsc, SortByAmountDesc, SortByAmountAsc:
+		return nil
+	}
+	return errors.Errorf("unsupported sort order %q", string(s))
+}
+
+// Descending reports whether the sort order is descending
+func (s OrderSort) Descending() bool {
+	return len(s) > 0 && s[0] == '-'
+}
+
+// Page selects a page of a listing
+type Page struct {
+	// Limit is a page size, DefaultPageLimit when zero
+	Limit int
+	// Sort is SortByCreatedAtDesc when empty
+	Sort OrderSort
+	// Cursor is OrderPage.NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// Normalize fills defaults and validates the page
+func (p Page) Normalize() (Page, error) {
+	if p.Sort == "" {
+		p.Sort = SortByCreatedAtDesc
+	}
+	if err := p.Sort.Validate(); err != nil {
+		return Page{}, err
+	}
+	switch {
+	case p.Limit < 0:
+		return Page{}, errors.Errorf("negative limit %d", p.Limit)
+	case p.Limit == 0:
+		p.Limit = DefaultPageLimit
+	case p.Limit > MaxPageLimit:
+		p.Limit = MaxPageLimit
+	}
+	return p, nil
+}
+
+// OrderPage is a page of listed orders
+type OrderPage struct {
+	Orders []*models.Order
+	// NextCursor points at the next page, it is empty on the last page
+	NextCursor string
+}
+
+// OrderCursor is a position in a listing: the sort key and the ID of the last order on a page.
+// Clients get it encoded as an opaque string.
+type OrderCursor struct {
+	Sort      OrderSort      `json:"s"`
+	CreatedAt time.Time      `json:"c,omitempty"`
+	Amount    models.Decimal `json:"a,omitempty"`
+	OrderID   models.OrderID `json:"i"`
+}
+
+// NewOrderCursor returns a cursor pointing right after the order
+func NewOrderCursor(sort OrderSort, order *models.Order) OrderCursor {
+	cursor := OrderCursor{Sort: sort, OrderID: order.ID}
+	switch sort {
+	case SortByAmountAsc, SortByAmountDesc:
+		cursor.Amount = order.Amount.Value
+	default:
+		cursor.CreatedAt = order.CreatedAt
+	}
+	return cursor
+}
+
+// Encode returns the cursor as an opaque string
+func (c OrderCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeOrderCursor parses a cursor of a listing sorted by sort
+func DecodeOrderCursor(s string, sort OrderSort) (OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return OrderCursor{}, errors.Wrap(err, "malformed cursor")
+	}
+
+	var cursor OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return OrderCursor{}, errors.Wrap(err, "malformed cursor")
+	}
+	if cursor.Sort != sort {
+		return OrderCursor{}, errors.Errorf("cursor of %q listing used for %q", cursor.Sort, sort)
+	}
+
+	return cursor, nil
+}
@@ -5,6 +5,7 @@
 package repositories
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/netology/dao-pattern/models"
 	reflect "reflect"
@@ -34,30 +35,208 @@ func (m *MockOrderRepository) EXPECT() *MockOrderRepositoryMockRecorder {
 }
 
 // GetByID mocks base method
-func (m *MockOrderRepository) GetByID(orderID models.OrderID) (*models.Order, error) {
+func (m *MockOrderRepository) GetByID(ctx context.Context, orderID models.OrderID, options ...QueryOption) (*models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByID", orderID)
+	varargs := []interface{}{ctx, orderID}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByID", varargs...)
 	ret0, _ := ret[0].(*models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByID indicates an expected call of GetByID
-func (mr *MockOrderRepositoryMockRecorder) GetByID(orderID interface{}) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) GetByID(ctx, orderID interface{}, options ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockOrderRepository)(nil).GetByID), orderID)
+	varargs := append([]interface{}{ctx, orderID}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockOrderRepository)(nil).GetByID), varargs...)
+}
+
+// GetByPublicID mocks base method
+func (m *MockOrderRepository) GetByPublicID(ctx context.Context, publicID string, options ...QueryOption) (*models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, publicID}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByPublicID", varargs...)
+	ret0, _ := ret[0].(*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByPublicID indicates an expected call of GetByPublicID
+func (mr *MockOrderRepositoryMockRecorder) GetByPublicID(ctx, publicID interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, publicID}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByPublicID", reflect.TypeOf((*MockOrderRepository)(nil).GetByPublicID), varargs...)
+}
+
+// GetByIDs mocks base method
+func (m *MockOrderRepository) GetByIDs(ctx context.Context, orderIDs []models.OrderID, options ...QueryOption) ([]*models.Order, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, orderIDs}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetByIDs", varargs...)
+	ret0, _ := ret[0].([]*models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIDs indicates an expected call of GetByIDs
+func (mr *MockOrderRepositoryMockRecorder) GetByIDs(ctx, orderIDs interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, orderIDs}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockOrderRepository)(nil).GetByIDs), varargs...)
 }
 
 // Save mocks base method
-func (m *MockOrderRepository) Save(order *models.Order) error {
+func (m *MockOrderRepository) Save(ctx context.Context, order *models.Order) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", order)
+	ret := m.ctrl.Call(m, "Save", ctx, order)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save
-func (mr *MockOrderRepositoryMockRecorder) Save(order interface{}) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) Save(ctx, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOrderRepository)(nil).Save), ctx, order)
+}
+
+// SaveIdempotent mocks base method
+func (m *MockOrderRepository) SaveIdempotent(ctx context.Context, key string, order *models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotent", ctx, key, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotent indicates an expected call of SaveIdempotent
+func (mr *MockOrderRepositoryMockRecorder) SaveIdempotent(ctx, key, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotent", reflect.TypeOf((*MockOrderRepository)(nil).SaveIdempotent), ctx, key, order)
+}
+
+// PurgeIdempotencyKeys mocks base method
+func (m *MockOrderRepository) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeIdempotencyKeys", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeIdempotencyKeys indicates an expected call of PurgeIdempotencyKeys
+func (mr *MockOrderRepositoryMockRecorder) PurgeIdempotencyKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeIdempotencyKeys", reflect.TypeOf((*MockOrderRepository)(nil).PurgeIdempotencyKeys), ctx)
+}
+
+// Update mocks base method
+func (m *MockOrderRepository) Update(ctx context.Context, order *models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, order)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update
+func (mr *MockOrderRepositoryMockRecorder) Update(ctx, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockOrderRepository)(nil).Update), ctx, order)
+}
+
+// Delete mocks base method
+func (m *MockOrderRepository) Delete(ctx context.Context, orderID models.OrderID, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, orderID, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockOrderRepositoryMockRecorder) Delete(ctx, orderID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockOrderRepository)(nil).Delete), ctx, orderID, version)
+}
+
+// SoftDelete mocks base method
+func (m *MockOrderRepository) SoftDelete(ctx context.Context, orderID models.OrderID, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDelete", ctx, orderID, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDelete indicates an expected call of SoftDelete
+func (mr *MockOrderRepositoryMockRecorder) SoftDelete(ctx, orderID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDelete", reflect.TypeOf((*MockOrderRepository)(nil).SoftDelete), ctx, orderID, version)
+}
+
+// Restore mocks base method
+func (m *MockOrderRepository) Restore(ctx context.Context, orderID models.OrderID, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, orderID, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore
+func (mr *MockOrderRepositoryMockRecorder) Restore(ctx, orderID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockOrderRepository)(nil).Restore), ctx, orderID, version)
+}
+
+// TransitionStatus mocks base method
+func (m *MockOrderRepository) TransitionStatus(ctx context.Context, orderID models.OrderID, from, to models.OrderStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransitionStatus", ctx, orderID, from, to)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransitionStatus indicates an expected call of TransitionStatus
+func (mr *MockOrderRepositoryMockRecorder) TransitionStatus(ctx, orderID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransitionStatus", reflect.TypeOf((*MockOrderRepository)(nil).TransitionStatus), ctx, orderID, from, to)
+}
+
+// List mocks base method
+func (m *MockOrderRepository) List(ctx context.Context, filter OrderFilter, page Page, options ...QueryOption) (*OrderPage, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, filter, page}
+	for _, a := range options {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "List", varargs...)
+	ret0, _ := ret[0].(*OrderPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockOrderRepositoryMockRecorder) List(ctx, filter, page interface{}, options ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, filter, page}, options...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOrderRepository)(nil).List), varargs...)
+}
+
+// Close mocks base method
+func (m *MockOrderRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockOrderRepositoryMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOrderRepository)(nil).Save), order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockOrderRepository)(nil).Close))
 }
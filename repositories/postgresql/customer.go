@@ -0,0 +1,188 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+const customerColumns = "customer_id, COALESCE(public_id, customer_id::text), balance, currency, created_at, updated_at"
+
+func NewCustomerRepository(db *sql.DB, options ...Option) repositories.CustomerRepository {
+	return &customer{
+		settings: newSettings(options),
+		db:       db,
+		stmts:    newStatements(db),
+	}
+}
+
+type customer struct {
+	settings
+	db    *sql.DB
+	stmts *statements
+}
+
+func scanCustomer(row rowScanner) (*models.Customer, error) {
+	customer := &models.Customer{}
+	err := row.Scan(&customer.ID, &customer.PublicID, &customer.Balance.Value, &customer.Balance.Currency, &customer.CreatedAt, &customer.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	utc(&customer.CreatedAt, &customer.UpdatedAt)
+	return customer, nil
+}
+
+func (c *customer) GetByID(ctx context.Context, customerID models.CustomerID) (*models.Customer, error) {
+	stmt, err := c.stmts.prepare(ctx, "SELECT "+customerColumns+" FROM customers WHERE customer_id=$1")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	customer, err := scanCustomer(stmt.QueryRowContext(ctx, customerID))
+	if err != nil {
+		return nil, errors.Wrapf(mapError(err), "customer %d", customerID)
+	}
+
+	return customer, nil
+}
+
+func (c *customer) GetByPublicID(ctx context.Context, publicID string) (*models.Customer, error) {
+	stmt, err := c.stmts.prepare(ctx, "SELECT "+customerColumns+" FROM customers WHERE COALESCE(public_id, customer_id::text)=$1")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	customer, err := scanCustomer(stmt.QueryRowContext(ctx, publicID))
+	if err != nil {
+		return nil, errors.Wrapf(mapError(err), "customer %q", publicID)
+	}
+
+	return customer, nil
+}
+
+func (c *customer) Save(ctx context.Context, customer *models.Customer) error {
+	if err := customer.Balance.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate balance error")
+	}
+
+	stmt, err := c.stmts.prepare(ctx, "INSERT INTO customers (balance, currency, created_at, updated_at, public_id) VALUES ($1, $2, $3, $3, $4) RETURNING customer_id")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	generated, err := c.newPublicID(customer.PublicID)
+	if err != nil {
+		return err
+	}
+
+	var lastInsertID int64
+	now := c.now()
+	if err := stmt.QueryRowContext(ctx, customer.Balance.Value, customer.Balance.Currency, now, generated).Scan(&lastInsertID); err != nil {
+		return errors.Wrap(mapError(err), "query row error")
+	}
+
+	customer.ID = models.CustomerID(lastInsertID)
+	customer.PublicID = publicID(generated, int(customer.ID))
+	customer.CreatedAt, customer.UpdatedAt = now, now
+
+	return nil
+}
+
+func (c *customer) Update(ctx context.Context, customer *models.Customer) error {
+	if err := customer.Balance.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate balance error")
+	}
+
+	stmt, err := c.stmts.prepare(ctx, "UPDATE customers SET balance=$2, currency=$3, updated_at=$4 WHERE customer_id=$1")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	now := c.now()
+	result, err := stmt.ExecContext(ctx, customer.ID, customer.Balance.Value, customer.Balance.Currency, now)
+	if err != nil {
+		return errors.Wrap(mapError(err), "exec error")
+	}
+	if err := expectAffected(result); err != nil {
+		return errors.Wrapf(mapError(err), "customer %d", customer.ID)
+	}
+
+	customer.UpdatedAt = now
+	return nil
+}
+
+func (c *customer) List(ctx context.Context, limit, offset int) ([]*models.Customer, error) {
+	stmt, err := c.stmts.prepare(ctx, "SELECT "+customerColumns+" FROM customers ORDER BY customer_id LIMIT $1 OFFSET $2")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	rows, err := stmt.QueryContext(ctx, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
+	customers := []*models.Customer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, errors.Wrap(mapError(err), "scan error")
+		}
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
+
+	return customers, nil
+}
+
+func (c *customer) AdjustBalance(ctx context.Context, customerID models.CustomerID, delta models.Money) (models.Money, error) {
+	const (
+		lock   = "SELECT balance, currency FROM customers WHERE customer_id=$1 FOR UPDATE"
+		update = "UPDATE customers SET balance=$2, updated_at=$3 WHERE customer_id=$1"
+	)
+	if err := c.stmts.warm(ctx, lock, update); err != nil {
+		return models.Money{}, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	var balance models.Money
+	err := transact(ctx, c.db, repositories.TxOptions{}, c.retryPolicy, func(ctx context.Context) error {
+		stmt, err := c.stmts.prepare(ctx, lock)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+		if err := stmt.QueryRowContext(ctx, customerID).Scan(&balance.Value, &balance.Currency); err != nil {
+			return errors.Wrapf(mapError(err), "customer %d", customerID)
+		}
+
+		balance, err = balance.Add(delta)
+		if err != nil {
+			return errors.Wrap(validationError(err), "adjust balance error")
+		}
+
+		stmt, err = c.stmts.prepare(ctx, update)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+		if _, err := stmt.ExecContext(ctx, customerID, balance.Value, c.now()); err != nil {
+			return errors.Wrap(mapError(err), "exec error")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	return balance, nil
+}
+
+func (c *customer) Close() error {
+	return c.stmts.Close()
+}
@@ -0,0 +1,284 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+func TestCustomer_GetByID(t *testing.T) {
+	expectedCustomerID := models.CustomerID(3)
+	createdAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare("SELECT customer_id, COALESCE\\(public_id, customer_id::text\\), balance, currency, created_at, updated_at FROM customers").ExpectQuery().
+			WithArgs(expectedCustomerID).
+			WillReturnRows(sqlmock.NewRows([]string{"customer_id", "public_id", "balance", "currency", "created_at", "updated_at"}).AddRow(expectedCustomerID, "3", "10.5000", "usd", createdAt, createdAt))
+
+		customerRepository := NewCustomerRepository(db)
+		customer, err := customerRepository.GetByID(context.Background(), expectedCustomerID)
+		require.NoError(t, err)
+		require.Equal(t, &models.Customer{
+			ID:        expectedCustomerID,
+			PublicID:  "3",
+			Balance:   models.Money{Value: models.MustParseDecimal("10.5"), Currency: models.USD},
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}, customer)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("customer does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT customer_id, COALESCE\\(public_id, customer_id::text\\), balance, currency, created_at, updated_at FROM customers").ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"customer_id", "public_id", "balance", "currency", "created_at", "updated_at"}))
+
+			customerRepository := NewCustomerRepository(db)
+			customer, err := customerRepository.GetByID(context.Background(), expectedCustomerID)
+			require.Nil(t, customer)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestCustomer_GetByPublicID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare(`SELECT (.+) FROM customers WHERE COALESCE\(public_id, customer_id::text\)=\$1`).ExpectQuery().
+		WithArgs("3").
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "public_id", "balance", "currency", "created_at", "updated_at"}).AddRow(3, "3", "1.0000", "usd", time.Now(), time.Now()))
+
+	customerRepository := NewCustomerRepository(db)
+	customer, err := customerRepository.GetByPublicID(context.Background(), "3")
+	require.NoError(t, err)
+	require.Equal(t, models.CustomerID(3), customer.ID)
+}
+
+func TestCustomer_Save(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`INSERT INTO customers \(balance, currency, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$3, \$4\) RETURNING customer_id`).
+			ExpectQuery().
+			WithArgs("100.0000", "eur", sqlmock.AnyArg(), nil).
+			WillReturnRows(sqlmock.NewRows([]string{"customer_id"}).AddRow(9))
+
+		customer := &models.Customer{Balance: models.Money{Value: models.MustDecimalFromInt(100), Currency: models.EUR}}
+		customerRepository := NewCustomerRepository(db)
+		err = customerRepository.Save(context.Background(), customer)
+		require.NoError(t, err)
+		require.Equal(t, models.CustomerID(9), customer.ID)
+		require.Equal(t, "9", customer.PublicID)
+	})
+
+	t.Run("assigned public id", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`INSERT INTO customers`).
+			ExpectQuery().
+			WithArgs("100.0000", "eur", sqlmock.AnyArg(), "customer-9").
+			WillReturnRows(sqlmock.NewRows([]string{"customer_id"}).AddRow(9))
+
+		generator := repositories.IDGeneratorFunc(func() (string, error) {
+			return "", errors.New("generator must not be called")
+		})
+		customer := &models.Customer{PublicID: "customer-9", Balance: models.Money{Value: models.MustDecimalFromInt(100), Currency: models.EUR}}
+		customerRepository := NewCustomerRepository(db, WithIDGenerator(generator))
+		err = customerRepository.Save(context.Background(), customer)
+		require.NoError(t, err)
+		require.Equal(t, "customer-9", customer.PublicID)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("prepare and query return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`INSERT INTO customers`).ExpectQuery().WillReturnError(dummyError)
+
+			customerRepository := NewCustomerRepository(db)
+			err = customerRepository.Save(context.Background(), &models.Customer{Balance: models.Money{Currency: models.EUR}})
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), dummyError)
+		})
+
+		t.Run("unknown currency", func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			customerRepository := NewCustomerRepository(db)
+			err = customerRepository.Save(context.Background(), &models.Customer{Balance: models.Money{Currency: "zzz"}})
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+		})
+	})
+}
+
+func TestCustomer_Update(t *testing.T) {
+	customer := &models.Customer{ID: 3, Balance: models.Money{Value: models.MustDecimalFromInt(5), Currency: models.USD}}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`UPDATE customers SET balance=\$2, currency=\$3, updated_at=\$4 WHERE customer_id=\$1`).
+			ExpectExec().
+			WithArgs(3, "5.0000", "usd", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		customerRepository := NewCustomerRepository(db)
+		err = customerRepository.Update(context.Background(), customer)
+		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("customer does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE customers`).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+
+			customerRepository := NewCustomerRepository(db)
+			err = customerRepository.Update(context.Background(), customer)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestCustomer_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare(`SELECT customer_id, COALESCE\(public_id, customer_id::text\), balance, currency, created_at, updated_at FROM customers ORDER BY customer_id LIMIT \$1 OFFSET \$2`).
+		ExpectQuery().
+		WithArgs(2, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "public_id", "balance", "currency", "created_at", "updated_at"}).
+			AddRow(11, "11", "1.0000", "usd", time.Now(), time.Now()).
+			AddRow(12, "12", "2.0000", "eur", time.Now(), time.Now()))
+
+	customerRepository := NewCustomerRepository(db)
+	customers, err := customerRepository.List(context.Background(), 2, 10)
+	require.NoError(t, err)
+	require.Len(t, customers, 2)
+	require.Equal(t, models.CustomerID(12), customers[1].ID)
+	require.Equal(t, models.EUR, customers[1].Balance.Currency)
+}
+
+func TestCustomer_AdjustBalance(t *testing.T) {
+	expectedCustomerID := models.CustomerID(3)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT balance, currency FROM customers WHERE customer_id=\$1 FOR UPDATE`)
+		now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare(`UPDATE customers SET balance=\$2, updated_at=\$3 WHERE customer_id=\$1`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT balance, currency FROM customers WHERE customer_id=\$1 FOR UPDATE`).
+			WithArgs(expectedCustomerID).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "currency"}).AddRow("10.1000", "usd"))
+		mock.ExpectExec(`UPDATE customers SET balance=\$2, updated_at=\$3 WHERE customer_id=\$1`).
+			WithArgs(expectedCustomerID, "7.9000", now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		customerRepository := NewCustomerRepository(db, WithClock(repositories.ClockFunc(func() time.Time { return now })))
+		balance, err := customerRepository.AdjustBalance(context.Background(), expectedCustomerID, models.Money{Value: models.MustParseDecimal("-2.2"), Currency: models.USD})
+		require.NoError(t, err)
+		require.Equal(t, models.Money{Value: models.MustParseDecimal("7.9"), Currency: models.USD}, balance)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("currency mismatch", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT balance, currency FROM customers`)
+			mock.ExpectPrepare(`UPDATE customers`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT balance, currency FROM customers`).
+				WillReturnRows(sqlmock.NewRows([]string{"balance", "currency"}).AddRow("10.0000", "usd"))
+			mock.ExpectRollback()
+
+			customerRepository := NewCustomerRepository(db)
+			_, err = customerRepository.AdjustBalance(context.Background(), expectedCustomerID, models.Money{Value: models.MustDecimalFromInt(1), Currency: models.EUR})
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+			require.True(t, errors.Is(err, models.ErrCurrencyMismatch))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("customer does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT balance, currency FROM customers`)
+			mock.ExpectPrepare(`UPDATE customers`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT balance, currency FROM customers`).
+				WillReturnRows(sqlmock.NewRows([]string{"balance", "currency"}))
+			mock.ExpectRollback()
+
+			customerRepository := NewCustomerRepository(db)
+			_, err = customerRepository.AdjustBalance(context.Background(), expectedCustomerID, models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD})
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
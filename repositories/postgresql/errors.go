@@ -0,0 +1,84 @@
+package postgresql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// errorKinds maps SQLSTATE codes to repository errors,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html
+var errorKinds = map[pq.ErrorCode]error{
+	"23505": repositories.ErrConflict,
+	"23503": repositories.ErrInvalidReference,
+	"23502": repositories.ErrValidation,
+	"23514": repositories.ErrValidation,
+	"22003": repositories.ErrValidation,
+	"25006": repositories.ErrReadOnly,
+	"40001": repositories.ErrSerialization,
+	"40P01": repositories.ErrSerialization,
+	"57P01": repositories.ErrConnectionLost,
+	"57P02": repositories.ErrConnectionLost,
+	"57P03": repositories.ErrConnectionLost,
+}
+
+// referencedEntities maps foreign key constraints to entities they point at
+var referencedEntities = map[string]string{
+	"orders_customer_id_fkey":     "customer",
+	"order_items_order_id_fkey":   "order",
+	"order_items_product_id_fkey": "product",
+}
+
+// missingKeyDetail matches a foreign key violation detail like
+// Key (customer_id)=(42) is not present in table "customers".
+var missingKeyDetail = regexp.MustCompile(`^Key \([^)]+\)=\(([^)]*)\) is not present`)
+
+// mapError classifies a driver error as one of repositories errors,
+// unknown errors are returned as is
+func mapError(err error) error {
+	var repositoryError *repositories.Error
+	if err == nil || errors.As(err, &repositoryError) {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &repositories.Error{Kind: repositories.ErrNotFound, Err: err}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, io.ErrUnexpectedEOF):
+		return &repositories.Error{Kind: repositories.ErrConnectionLost, Err: err}
+	}
+
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) {
+		return err
+	}
+
+	kind, ok := errorKinds[pqError.Code]
+	if !ok && pqError.Code.Class() == "08" {
+		kind, ok = repositories.ErrConnectionLost, true
+	}
+	if !ok {
+		return err
+	}
+
+	repositoryError = &repositories.Error{Kind: kind, Constraint: pqError.Constraint, Err: err}
+	if kind == repositories.ErrInvalidReference {
+		if match := missingKeyDetail.FindStringSubmatch(pqError.Detail); match != nil {
+			repositoryError.Entity = referencedEntities[pqError.Constraint]
+			repositoryError.Key = match[1]
+		}
+	}
+
+	return repositoryError
+}
+
+// validationError marks err as repositories.ErrValidation
+func validationError(err error) error {
+	return &repositories.Error{Kind: repositories.ErrValidation, Err: err}
+}
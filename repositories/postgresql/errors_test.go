@@ -0,0 +1,82 @@
+// +build unit
+
+package postgresql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/lib/pq"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMapError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"no rows", sql.ErrNoRows, repositories.ErrNotFound},
+		{"wrapped no rows", errors.Wrap(sql.ErrNoRows, "scan"), repositories.ErrNotFound},
+		{"bad connection", driver.ErrBadConn, repositories.ErrConnectionLost},
+		{"unique violation", &pq.Error{Code: "23505", Constraint: "orders_pkey"}, repositories.ErrConflict},
+		{"foreign key violation", &pq.Error{Code: "23503"}, repositories.ErrInvalidReference},
+		{"check violation", &pq.Error{Code: "23514"}, repositories.ErrValidation},
+		{"not null violation", &pq.Error{Code: "23502"}, repositories.ErrValidation},
+		{"serialization failure", &pq.Error{Code: "40001"}, repositories.ErrSerialization},
+		{"deadlock", &pq.Error{Code: "40P01"}, repositories.ErrSerialization},
+		{"read-only transaction", &pq.Error{Code: "25006"}, repositories.ErrReadOnly},
+		{"connection failure", &pq.Error{Code: "08006"}, repositories.ErrConnectionLost},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, repositories.ErrConnectionLost},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := mapError(tc.err)
+			require.True(t, errors.Is(err, tc.expected), err.Error())
+			require.True(t, errors.Is(err, tc.err))
+			require.Equal(t, err, mapError(err))
+		})
+	}
+
+	t.Run("constraint is reported", func(t *testing.T) {
+		var repositoryError *repositories.Error
+		err := mapError(&pq.Error{Code: "23505", Constraint: "orders_pkey"})
+		require.True(t, errors.As(err, &repositoryError))
+		require.Equal(t, "orders_pkey", repositoryError.Constraint)
+	})
+
+	t.Run("missing reference is named", func(t *testing.T) {
+		var repositoryError *repositories.Error
+		err := mapError(&pq.Error{
+			Code:       "23503",
+			Constraint: "orders_customer_id_fkey",
+			Detail:     `Key (customer_id)=(42) is not present in table "customers".`,
+		})
+		require.True(t, errors.As(err, &repositoryError))
+		require.Equal(t, "customer", repositoryError.Entity)
+		require.Equal(t, "42", repositoryError.Key)
+		require.Contains(t, err.Error(), "invalid reference to customer 42")
+	})
+
+	t.Run("still referenced entity is not named", func(t *testing.T) {
+		var repositoryError *repositories.Error
+		err := mapError(&pq.Error{
+			Code:       "23503",
+			Constraint: "order_items_product_id_fkey",
+			Detail:     `Key (product_id)=(1) is still referenced from table "order_items".`,
+		})
+		require.True(t, errors.As(err, &repositoryError))
+		require.Empty(t, repositoryError.Entity)
+	})
+
+	t.Run("unknown errors are kept as is", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+		require.Equal(t, dummyError, mapError(dummyError))
+
+		syntaxError := &pq.Error{Code: "42601"}
+		require.Equal(t, syntaxError, mapError(syntaxError))
+
+		require.Nil(t, mapError(nil))
+	})
+}
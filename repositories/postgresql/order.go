@@ -1,85 +1,680 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"time"
+
 	"github.com/pkg/errors"
+
 	"github.com/netology/dao-pattern/models"
 	"github.com/netology/dao-pattern/repositories"
 )
 
-func NewOrderRepository(db *sql.DB, orderItemRepository repositories.OrderItemRepository) repositories.OrderRepository {
-	return &order{
+const orderColumns = "order_id, COALESCE(public_id, order_id::text), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at"
+
+// DefaultBulkInsertThreshold is the number of new items above which
+// Save and Update insert them with a single COPY
+const DefaultBulkInsertThreshold = 50
+
+// OrderOption configures an order repository, every Option is an OrderOption
+type OrderOption interface {
+	applyOrder(*order)
+}
+
+type orderOptionFunc func(*order)
+
+func (f orderOptionFunc) applyOrder(o *order) {
+	f(o)
+}
+
+func (f Option) applyOrder(o *order) {
+	f(&o.settings)
+}
+
+// WithStrictValidation makes Save and Update reject orders which fail
+// models.Order.Validate, e.g. when Amount differs from the items total
+func WithStrictValidation() OrderOption {
+	return orderOptionFunc(func(o *order) {
+		o.strict = true
+	})
+}
+
+// WithBulkInsertThreshold makes Save and Update insert new items with
+// OrderItemRepository.SaveAll when there are more than n of them
+func WithBulkInsertThreshold(n int) OrderOption {
+	return orderOptionFunc(func(o *order) {
+		o.bulkInsertThreshold = n
+	})
+}
+
+// WithIdempotencyKeyTTL makes SaveIdempotent replay orders for ttl
+// instead of repositories.DefaultIdempotencyKeyTTL
+func WithIdempotencyKeyTTL(ttl time.Duration) OrderOption {
+	return orderOptionFunc(func(o *order) {
+		o.idempotencyKeyTTL = ttl
+	})
+}
+
+func NewOrderRepository(db *sql.DB, orderItemRepository repositories.OrderItemRepository, options ...OrderOption) repositories.OrderRepository {
+	o := &order{
 		db:                  db,
+		stmts:               newStatements(db),
 		orderItemRepository: orderItemRepository,
+		bulkInsertThreshold: DefaultBulkInsertThreshold,
+		idempotencyKeyTTL:   repositories.DefaultIdempotencyKeyTTL,
+		settings:            newSettings(nil),
+	}
+	for _, option := range options {
+		option.applyOrder(o)
 	}
+	return o
 }
 
 type order struct {
+	settings
 	db                  *sql.DB
+	stmts               *statements
 	orderItemRepository repositories.OrderItemRepository
+	strict              bool
+	bulkInsertThreshold int
+	idempotencyKeyTTL   time.Duration
 }
 
-func (o *order) GetByID(orderID models.OrderID) (*models.Order, error) {
-	stmt, err := o.db.Prepare("SELECT order_id, customer_id, amount, currency FROM orders WHERE order_id=$1")
+func scanOrder(row rowScanner) (*models.Order, error) {
+	order := &models.Order{}
+	err := row.Scan(&order.ID, &order.PublicID, &order.CustomerID, &order.Amount.Value, &order.Amount.Currency, &order.Status, &order.CreatedAt, &order.UpdatedAt, &order.Version, &order.DeletedAt)
 	if err != nil {
-		return nil, errors.Wrap(err, "prepare")
+		return nil, err
 	}
+	utc(&order.CreatedAt, &order.UpdatedAt)
+	order.DeletedAt = utcNullable(order.DeletedAt)
+	return order, nil
+}
 
-	order := &models.Order{}
-	err = stmt.QueryRow(orderID).Scan(&order.ID, &order.CustomerID, &order.Amount.Value, &order.Amount.Currency)
+// notDeleted is a condition which skips soft deleted rows unless options include them
+func notDeleted(options []repositories.QueryOption) string {
+	if repositories.NewQueryOptions(options...).IncludeDeleted {
+		return ""
+	}
+	return " AND deleted_at IS NULL"
+}
+
+func (o *order) GetByID(ctx context.Context, orderID models.OrderID, options ...repositories.QueryOption) (*models.Order, error) {
+	stmt, err := o.stmts.prepare(ctx, "SELECT "+orderColumns+" FROM orders WHERE order_id=$1"+notDeleted(options))
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	order, err := scanOrder(stmt.QueryRowContext(ctx, orderID))
 	if err != nil {
-		return nil, errors.Wrap(err, "prepare")
+		return nil, errors.Wrapf(mapError(err), "order %d", orderID)
 	}
 
-	orderItems, err := o.orderItemRepository.GetByOrderID(order.ID)
+	orderItems, err := o.orderItemRepository.GetByOrderID(ctx, order.ID, options...)
 	if err != nil {
-		return nil, errors.Wrap(err, "prepare")
+		return nil, errors.Wrap(err, "get order items error")
 	}
 	order.Items = orderItems
 
 	return order, nil
 }
 
-func (o *order) Save(order *models.Order) error {
-	tx, err := o.db.Begin()
+func (o *order) GetByPublicID(ctx context.Context, publicID string, options ...repositories.QueryOption) (*models.Order, error) {
+	stmt, err := o.stmts.prepare(ctx, "SELECT "+orderColumns+" FROM orders WHERE COALESCE(public_id, order_id::text)=$1"+notDeleted(options))
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	order, err := scanOrder(stmt.QueryRowContext(ctx, publicID))
+	if err != nil {
+		return nil, errors.Wrapf(mapError(err), "order %q", publicID)
+	}
+
+	orderItems, err := o.orderItemRepository.GetByOrderID(ctx, order.ID, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "get order items error")
+	}
+	order.Items = orderItems
+
+	return order, nil
+}
+
+func (o *order) GetByIDs(ctx context.Context, orderIDs []models.OrderID, options ...repositories.QueryOption) ([]*models.Order, error) {
+	if len(orderIDs) == 0 {
+		return []*models.Order{}, nil
+	}
+
+	stmt, err := o.stmts.prepare(ctx, "SELECT "+orderColumns+" FROM orders WHERE order_id = ANY($1)"+notDeleted(options))
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	rows, err := stmt.QueryContext(ctx, orderIDArray(orderIDs))
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
+	found := make(map[models.OrderID]*models.Order, len(orderIDs))
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(mapError(err), "scan error")
+		}
+		found[order.ID] = order
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
+
+	orders := make([]*models.Order, 0, len(found))
+	for _, orderID := range orderIDs {
+		if order, ok := found[orderID]; ok {
+			orders = append(orders, order)
+			delete(found, orderID)
+		}
+	}
+
+	if err := o.loadItems(ctx, orders, options); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// loadItems fills items of all orders with a single query
+func (o *order) loadItems(ctx context.Context, orders []*models.Order, options []repositories.QueryOption) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	orderIDs := make([]models.OrderID, len(orders))
+	for i, order := range orders {
+		orderIDs[i] = order.ID
+	}
+
+	orderItems, err := o.orderItemRepository.GetByOrderIDs(ctx, orderIDs, options...)
+	if err != nil {
+		return errors.Wrap(err, "get order items error")
+	}
+	for _, order := range orders {
+		order.Items = orderItems[order.ID]
+		if order.Items == nil {
+			order.Items = []*models.OrderItem{}
+		}
+	}
+
+	return nil
+}
+
+const insertOrder = "INSERT INTO orders (customer_id, amount, currency, status, created_at, updated_at, public_id) VALUES ($1, $2, $3, $4, $5, $5, $6) RETURNING order_id, version"
+
+func (o *order) Save(ctx context.Context, order *models.Order) error {
+	if err := o.validateNew(order); err != nil {
+		return err
+	}
+
+	if err := o.stmts.warm(ctx, insertOrder); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	generated, err := o.newPublicID(order.PublicID)
 	if err != nil {
-		return errors.Wrap(err, "begin transaction error")
+		return err
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO orders (customer_id, amount, currency) VALUES ($1, $2, $3) RETURNING order_id")
+	now := o.now()
+	snapshot := snapshotOrder(order)
+	err = transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		snapshot.restore(order)
+		return o.insert(ctx, order, generated, now)
+	})
 	if err != nil {
-		return errors.Wrap(err, "prepare query error")
+		snapshot.restore(order)
+	}
+	return err
+}
+
+// SaveIdempotent takes the key with an upsert which succeeds only for a new or
+// an expired key. A concurrent request with the same key waits until the first
+// one commits and then replays its order.
+func (o *order) SaveIdempotent(ctx context.Context, key string, order *models.Order) error {
+	if err := o.validateNew(order); err != nil {
+		return err
+	}
+
+	const (
+		take = "INSERT INTO idempotency_keys (key, request_hash, created_at) VALUES ($1, $2, $3) " +
+			"ON CONFLICT (key) DO UPDATE SET request_hash=EXCLUDED.request_hash, order_id=NULL, created_at=EXCLUDED.created_at " +
+			"WHERE idempotency_keys.created_at < $4 RETURNING key"
+		bind = "UPDATE idempotency_keys SET order_id=$2 WHERE key=$1"
+	)
+	if err := o.stmts.warm(ctx, take, bind, insertOrder); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	generated, err := o.newPublicID(order.PublicID)
+	if err != nil {
+		return err
 	}
 
+	hash := repositories.OrderRequestHash(order)
+	now := o.now()
+	snapshot := snapshotOrder(order)
+	err = transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		snapshot.restore(order)
+
+		stmt, err := o.stmts.prepare(ctx, take)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+
+		var taken string
+		err = stmt.QueryRowContext(ctx, key, hash, now, now.Add(-o.idempotencyKeyTTL)).Scan(&taken)
+		if err == sql.ErrNoRows {
+			return o.replay(ctx, key, hash, order)
+		}
+		if err != nil {
+			return errors.Wrap(mapError(err), "take idempotency key error")
+		}
+
+		if err := o.insert(ctx, order, generated, now); err != nil {
+			return err
+		}
+
+		stmt, err = o.stmts.prepare(ctx, bind)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+		if _, err := stmt.ExecContext(ctx, key, order.ID); err != nil {
+			return errors.Wrap(mapError(err), "bind idempotency key error")
+		}
+		return nil
+	})
+	if err != nil {
+		snapshot.restore(order)
+	}
+	return err
+}
+
+// replay fills order with the one created with the key when the request matches
+func (o *order) replay(ctx context.Context, key, hash string, order *models.Order) error {
+	stmt, err := o.stmts.prepare(ctx, "SELECT request_hash, order_id FROM idempotency_keys WHERE key=$1")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	var storedHash string
+	var orderID models.OrderID
+	if err := stmt.QueryRowContext(ctx, key).Scan(&storedHash, &orderID); err != nil {
+		return errors.Wrapf(mapError(err), "idempotency key %q", key)
+	}
+	if storedHash != hash {
+		return &repositories.Error{
+			Kind: repositories.ErrIdempotencyKeyReused,
+			Key:  key,
+			Err:  errors.Errorf("idempotency key %q was used for another order", key),
+		}
+	}
+
+	stored, err := o.GetByID(ctx, orderID, repositories.IncludeDeleted())
+	if err != nil {
+		return errors.Wrap(err, "replay order error")
+	}
+	*order = *stored
+	return nil
+}
+
+func (o *order) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	stmt, err := o.stmts.prepare(ctx, "DELETE FROM idempotency_keys WHERE created_at < $1")
+	if err != nil {
+		return 0, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	result, err := stmt.ExecContext(ctx, o.now().Add(-o.idempotencyKeyTTL))
+	if err != nil {
+		return 0, errors.Wrap(mapError(err), "exec error")
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(mapError(err), "rows affected error")
+	}
+	return purged, nil
+}
+
+// validateNew defaults the status of an order to be inserted and validates the order,
+// which starts as a draft and reaches other statuses by TransitionStatus
+func (o *order) validateNew(order *models.Order) error {
+	if order.Status == "" {
+		order.Status = models.OrderStatusDraft
+	}
+	if err := order.Status.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate status error")
+	}
+	if order.Status != models.OrderStatusDraft {
+		err := errors.Wrapf(models.ErrIllegalTransition, "new order is %s, not %s", order.Status, models.OrderStatusDraft)
+		return errors.Wrap(validationError(err), "validate status error")
+	}
+	return o.validate(order)
+}
+
+// insert stores the order with its items in the transaction carried by ctx
+func (o *order) insert(ctx context.Context, order *models.Order, generated sql.NullString, now time.Time) error {
+	stmt, err := o.stmts.prepare(ctx, insertOrder)
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	order.CreatedAt, order.UpdatedAt = now, now
 	var lastInsertID int64
-	if err := stmt.QueryRow(order.CustomerID, order.Amount.Value, order.Amount.Currency).Scan(&lastInsertID); err != nil {
-		return errors.Wrap(err, "query row error")
+	if err := stmt.QueryRowContext(ctx, order.CustomerID, order.Amount.Value, order.Amount.Currency, order.Status, now, generated).Scan(&lastInsertID, &order.Version); err != nil {
+		return errors.Wrap(mapError(err), "query row error")
 	}
 
 	/////////////// Alternative Usage: If pq (postgresql) driver support lastInsertID ////////////////////
 	//
-	// result, err := stmt.Exec(order.CustomerID, order.Amount.Value, order.Amount.Currency)
+	// result, err := stmt.ExecContext(ctx, order.CustomerID, order.Amount.Value, order.Amount.Currency)
 	// if err != nil {
-	//	return errors.Wrap(err, "exec error")
+	//	return errors.Wrap(mapError(err), "exec error")
 	// }
 	// lastInsertID, err = result.LastInsertId()
 	//
 	///////////////////////////////////////////////////////////////////////////////////////////////////////
 
 	order.ID = models.OrderID(lastInsertID)
+	order.PublicID = publicID(generated, int(order.ID))
 	for _, item := range order.Items {
 		item.OrderID = order.ID
-		if err := o.orderItemRepository.SaveWithTransaction(tx, item); err != nil {
-			if e := tx.Rollback(); e != nil {
-				return errors.Wrap(err, e.Error())
-			}
+	}
+	return o.saveItems(ctx, order.Items)
+}
+
+func (o *order) Update(ctx context.Context, order *models.Order) error {
+	if err := o.validate(order); err != nil {
+		return err
+	}
+
+	const update = "UPDATE orders SET customer_id=$2, amount=$3, currency=$4, updated_at=$6, version=version+1 WHERE order_id=$1 AND version=$5 AND deleted_at IS NULL RETURNING version"
+	if err := o.stmts.warm(ctx, update); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	var version int64
+	now := o.now()
+	// new items get IDs in every attempt, a retried attempt must insert them again
+	snapshot := snapshotOrder(order)
+	err := transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		snapshot.restore(order)
+
+		stmt, err := o.stmts.prepare(ctx, update)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+
+		err = stmt.QueryRowContext(ctx, order.ID, order.CustomerID, order.Amount.Value, order.Amount.Currency, order.Version, now).Scan(&version)
+		if err == sql.ErrNoRows {
+			return errors.Wrap(o.versionConflict(ctx, order.ID, order.Version, false), "update order error")
+		}
+		if err != nil {
+			return errors.Wrap(mapError(err), "query row error")
+		}
+
+		return o.reconcileItems(ctx, order)
+	})
+	if err != nil {
+		snapshot.restore(order)
+		return err
+	}
+
+	order.Version, order.UpdatedAt = version, now
+
+	return nil
+}
+
+// reconcileItems makes stored order items match order.Items: items without ID
+// are inserted, changed ones are updated and missing ones are deleted
+func (o *order) reconcileItems(ctx context.Context, order *models.Order) error {
+	stored, err := o.orderItemRepository.GetByOrderID(ctx, order.ID)
+	if err != nil {
+		return errors.Wrap(err, "get order items error")
+	}
+
+	storedByID := make(map[int64]*models.OrderItem, len(stored))
+	for _, item := range stored {
+		storedByID[item.ID] = item
+	}
+
+	kept := make(map[int64]bool, len(order.Items))
+	var added []*models.OrderItem
+	for _, item := range order.Items {
+		item.OrderID = order.ID
+		if item.ID == 0 {
+			added = append(added, item)
+			continue
+		}
+
+		storedItem, ok := storedByID[item.ID]
+		if !ok {
+			return validationError(errors.Errorf("order item %d does not belong to order %d", item.ID, order.ID))
+		}
+		kept[item.ID] = true
+		if sameItem(storedItem, item) {
+			continue
+		}
+		if err := o.orderItemRepository.Update(ctx, item); err != nil {
+			return errors.Wrap(err, "update order item error")
+		}
+	}
+
+	if err := o.saveItems(ctx, added); err != nil {
+		return err
+	}
+
+	for _, item := range stored {
+		if kept[item.ID] {
+			continue
+		}
+		if err := o.orderItemRepository.Delete(ctx, item.ID); err != nil {
+			return errors.Wrap(err, "delete order item error")
+		}
+	}
+
+	return nil
+}
+
+// sameItem reports whether an item matches the stored one in everything but timestamps
+func sameItem(stored, item *models.OrderItem) bool {
+	return stored.OrderID == item.OrderID && stored.ProductID == item.ProductID &&
+		stored.Quantity == item.Quantity && stored.Price == item.Price
+}
+
+// orderSnapshot is an order with its items the way the caller passed them
+type orderSnapshot struct {
+	order models.Order
+	items []models.OrderItem
+}
+
+func snapshotOrder(order *models.Order) orderSnapshot {
+	snapshot := orderSnapshot{order: *order, items: make([]models.OrderItem, len(order.Items))}
+	for i, item := range order.Items {
+		snapshot.items[i] = *item
+	}
+	return snapshot
+}
+
+// restore undoes what an attempt set on the order and its items, e.g. IDs
+// which a rolled back transaction assigned, so it may be retried or saved again
+func (s orderSnapshot) restore(order *models.Order) {
+	*order = s.order
+	for i, item := range order.Items {
+		*item = s.items[i]
+	}
+}
+
+// saveItems inserts new items one by one or, above the threshold, all at once
+func (o *order) saveItems(ctx context.Context, items []*models.OrderItem) error {
+	if len(items) > o.bulkInsertThreshold {
+		return errors.Wrap(o.orderItemRepository.SaveAll(ctx, items), "save order items error")
+	}
+
+	for _, item := range items {
+		if err := o.orderItemRepository.Save(ctx, item); err != nil {
 			return errors.Wrap(err, "save order item error")
 		}
 	}
+	return nil
+}
+
+func (o *order) Delete(ctx context.Context, orderID models.OrderID, version int64) error {
+	const remove = "DELETE FROM orders WHERE order_id=$1 AND version=$2 AND deleted_at IS NULL"
+	if err := o.stmts.warm(ctx, remove); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	return transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		if err := o.orderItemRepository.DeleteByOrderID(ctx, orderID); err != nil {
+			return errors.Wrap(err, "delete order items error")
+		}
+
+		stmt, err := o.stmts.prepare(ctx, remove)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+
+		result, err := stmt.ExecContext(ctx, orderID, version)
+		if err != nil {
+			return errors.Wrap(mapError(err), "exec error")
+		}
+		if err := expectAffected(result); err != nil {
+			return errors.Wrap(o.versionConflict(ctx, orderID, version, false), "delete order error")
+		}
+
+		return nil
+	})
+}
+
+func (o *order) SoftDelete(ctx context.Context, orderID models.OrderID, version int64) error {
+	return o.markDeleted(ctx, orderID, version, true)
+}
+
+func (o *order) Restore(ctx context.Context, orderID models.OrderID, version int64) error {
+	return o.markDeleted(ctx, orderID, version, false)
+}
+
+// markDeleted soft deletes or restores the order together with its items
+func (o *order) markDeleted(ctx context.Context, orderID models.OrderID, version int64, deleted bool) error {
+	query, action := "UPDATE orders SET deleted_at=NULL, updated_at=$3, version=version+1 WHERE order_id=$1 AND version=$2 AND deleted_at IS NOT NULL", "restore"
+	if deleted {
+		query, action = "UPDATE orders SET deleted_at=$3, updated_at=$3, version=version+1 WHERE order_id=$1 AND version=$2 AND deleted_at IS NULL", "soft delete"
+	}
+	if err := o.stmts.warm(ctx, query); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	now := o.now()
+	return transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		stmt, err := o.stmts.prepare(ctx, query)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+
+		result, err := stmt.ExecContext(ctx, orderID, version, now)
+		if err != nil {
+			return errors.Wrap(mapError(err), "exec error")
+		}
+		if err := expectAffected(result); err != nil {
+			return errors.Wrap(o.versionConflict(ctx, orderID, version, !deleted), action+" order error")
+		}
+
+		if deleted {
+			err = o.orderItemRepository.SoftDeleteByOrderID(ctx, orderID, now)
+		} else {
+			err = o.orderItemRepository.RestoreByOrderID(ctx, orderID)
+		}
+		return errors.Wrap(err, action+" order items error")
+	})
+}
+
+func (o *order) TransitionStatus(ctx context.Context, orderID models.OrderID, from, to models.OrderStatus) error {
+	if err := from.ValidateTransition(to); err != nil {
+		return errors.Wrap(validationError(err), "validate transition error")
+	}
 
-	if err = tx.Commit(); err != nil {
-		return errors.Wrap(err, "commit error")
+	stmt, err := o.stmts.prepare(ctx, "UPDATE orders SET status=$3, updated_at=$4, version=version+1 WHERE order_id=$1 AND status=$2 AND deleted_at IS NULL")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	result, err := stmt.ExecContext(ctx, orderID, from, to, o.now())
+	if err != nil {
+		return errors.Wrap(mapError(err), "exec error")
+	}
+	if err := expectAffected(result); err == nil {
+		return nil
+	}
+
+	// nothing was updated, find out whether the order is missing or has moved on
+	stmt, err = o.stmts.prepare(ctx, "SELECT status FROM orders WHERE order_id=$1 AND deleted_at IS NULL")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	var current models.OrderStatus
+	if err := stmt.QueryRowContext(ctx, orderID).Scan(&current); err != nil {
+		return errors.Wrapf(mapError(err), "order %d", orderID)
+	}
+
+	return &repositories.Error{
+		Kind: repositories.ErrStaleStatus,
+		Err:  errors.Errorf("order %d is %s, not %s", orderID, current, from),
+	}
+}
+
+// versionConflict tells a missing order from one changed since it was read,
+// it is called when a statement checking the version changed nothing.
+// A soft deleted order counts as missing unless deleted is set, then only it counts.
+func (o *order) versionConflict(ctx context.Context, orderID models.OrderID, expected int64, deleted bool) error {
+	query := "SELECT version FROM orders WHERE order_id=$1 AND deleted_at IS NULL"
+	if deleted {
+		query = "SELECT version FROM orders WHERE order_id=$1 AND deleted_at IS NOT NULL"
+	}
+	stmt, err := o.stmts.prepare(ctx, query)
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	var current int64
+	if err := stmt.QueryRowContext(ctx, orderID).Scan(&current); err != nil {
+		return errors.Wrapf(mapError(err), "order %d", orderID)
 	}
 
+	return &repositories.Error{
+		Kind: repositories.ErrStaleVersion,
+		Err:  errors.Errorf("order %d is at version %d, not %d", orderID, current, expected),
+	}
+}
+
+// Close closes prepared statements, the order item repository is left open
+func (o *order) Close() error {
+	return o.stmts.Close()
+}
+
+// validate rejects orders which can not be stored and,
+// in strict mode, orders which are inconsistent
+func (o *order) validate(order *models.Order) error {
+	if o.strict {
+		if err := order.Validate(); err != nil {
+			return errors.Wrap(validationError(err), "validate order error")
+		}
+	}
+	if err := order.Amount.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate amount error")
+	}
+	for _, item := range order.Items {
+		if err := item.Price.Validate(); err != nil {
+			return errors.Wrap(validationError(err), "validate order item price error")
+		}
+	}
 	return nil
 }
@@ -0,0 +1,105 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"github.com/netology/dao-pattern/models"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+// BenchmarkOrder_Load compares loading orders one by one with batch loading.
+// sqlmock fails on any query it does not expect, so the number of expectations
+// set for a load is the exact number of queries sent, reported as queries/op.
+// Timings measure sqlmock, not network latency which dominates in production.
+func BenchmarkOrder_Load(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("GetByID/%d", n), func(b *testing.B) {
+			benchmarkOrderLoad(b, n, false)
+		})
+		b.Run(fmt.Sprintf("GetByIDs/%d", n), func(b *testing.B) {
+			benchmarkOrderLoad(b, n, true)
+		})
+	}
+}
+
+func benchmarkOrderLoad(b *testing.B, n int, batch bool) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		b.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	orderRepository := NewOrderRepository(db, NewOrderItemRepository(db))
+	orderIDs := make([]models.OrderID, n)
+	for i := range orderIDs {
+		orderIDs[i] = models.OrderID(i + 1)
+	}
+
+	var queries int
+	prepared := map[string]bool{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		queries = expectOrderLoad(mock, prepared, orderIDs, batch)
+		b.StartTimer()
+
+		if batch {
+			if _, err := orderRepository.GetByIDs(ctx, orderIDs); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		for _, orderID := range orderIDs {
+			if _, err := orderRepository.GetByID(ctx, orderID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.StopTimer()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(queries), "queries/op")
+}
+
+// expectOrderLoad sets expectations for loading the orders with one item each
+// and returns the number of expected queries. Repositories keep prepared
+// statements, so a query not in prepared is expected to be prepared first.
+func expectOrderLoad(mock sqlmock.Sqlmock, prepared map[string]bool, orderIDs []models.OrderID, batch bool) int {
+	expectQuery := func(query string) *sqlmock.ExpectedQuery {
+		if !prepared[query] {
+			prepared[query] = true
+			mock.ExpectPrepare(query)
+		}
+		return mock.ExpectQuery(query)
+	}
+
+	orderColumns := []string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}
+	itemColumns := []string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}
+	createdAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	if batch {
+		orders, items := sqlmock.NewRows(orderColumns), sqlmock.NewRows(itemColumns)
+		for _, orderID := range orderIDs {
+			orders.AddRow(orderID, fmt.Sprint(orderID), 1, "1.0000", "usd", "paid", createdAt, createdAt, 1, nil)
+			items.AddRow(orderID, orderID, 1, 1, "1.0000", "usd", time.Now(), time.Now(), nil)
+		}
+		expectQuery(`FROM orders WHERE order_id = ANY`).WillReturnRows(orders)
+		expectQuery(`FROM order_items WHERE order_id = ANY`).WillReturnRows(items)
+		return 2
+	}
+
+	for _, orderID := range orderIDs {
+		expectQuery(`FROM orders WHERE order_id=`).
+			WillReturnRows(sqlmock.NewRows(orderColumns).AddRow(orderID, fmt.Sprint(orderID), 1, "1.0000", "usd", "paid", createdAt, createdAt, 1, nil))
+		expectQuery(`FROM order_items WHERE order_id=`).
+			WillReturnRows(sqlmock.NewRows(itemColumns).AddRow(orderID, orderID, 1, 1, "1.0000", "usd", time.Now(), time.Now(), nil))
+	}
+	return 2 * len(orderIDs)
+}
@@ -1,78 +1,295 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 
 	"github.com/netology/dao-pattern/models"
 	"github.com/netology/dao-pattern/repositories"
 )
 
-func NewOrderItemRepository(db *sql.DB) repositories.OrderItemRepository {
+func NewOrderItemRepository(db *sql.DB, options ...Option) repositories.OrderItemRepository {
 	return &orderItem{
-		db: db,
+		settings: newSettings(options),
+		db:       db,
+		stmts:    newStatements(db),
 	}
 }
 
 type orderItem struct {
-	db *sql.DB
+	settings
+	db    *sql.DB
+	stmts *statements
 }
 
-func (o orderItem) GetByOrderID(orderID models.OrderID) ([]*models.OrderItem, error) {
-	stmt, err := o.db.Prepare("SELECT order_item_id, order_id, product_id, quantity, price, currency FROM order_items WHERE order_id=$1")
+const orderItemColumns = "order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at"
+
+func scanOrderItem(row rowScanner) (*models.OrderItem, error) {
+	orderItem := &models.OrderItem{}
+	err := row.Scan(&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity, &orderItem.Price.Value, &orderItem.Price.Currency,
+		&orderItem.CreatedAt, &orderItem.UpdatedAt, &orderItem.DeletedAt)
 	if err != nil {
-		return nil, errors.Wrap(err, "prepare")
+		return nil, err
 	}
+	utc(&orderItem.CreatedAt, &orderItem.UpdatedAt)
+	orderItem.DeletedAt = utcNullable(orderItem.DeletedAt)
+	return orderItem, nil
+}
 
-	rows, err := stmt.Query(orderID)
+func (o orderItem) GetByOrderIDs(ctx context.Context, orderIDs []models.OrderID, options ...repositories.QueryOption) (map[models.OrderID][]*models.OrderItem, error) {
+	orderItems := make(map[models.OrderID][]*models.OrderItem, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return orderItems, nil
+	}
+	for _, orderID := range orderIDs {
+		orderItems[orderID] = []*models.OrderItem{}
+	}
+
+	stmt, err := o.stmts.prepare(ctx, "SELECT "+orderItemColumns+" FROM order_items WHERE order_id = ANY($1)"+notDeleted(options)+" ORDER BY order_id, order_item_id")
 	if err != nil {
-		return nil, errors.Wrap(err, "prepare")
+		return nil, errors.Wrap(mapError(err), "prepare query error")
 	}
 
+	rows, err := stmt.QueryContext(ctx, orderIDArray(orderIDs))
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		orderItem, err := scanOrderItem(rows)
+		if err != nil {
+			return nil, errors.Wrap(mapError(err), "scan error")
+		}
+		orderItems[orderItem.OrderID] = append(orderItems[orderItem.OrderID], orderItem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
+
+	return orderItems, nil
+}
+
+func (o orderItem) GetByOrderID(ctx context.Context, orderID models.OrderID, options ...repositories.QueryOption) ([]*models.OrderItem, error) {
+	stmt, err := o.stmts.prepare(ctx, "SELECT "+orderItemColumns+" FROM order_items WHERE order_id=$1"+notDeleted(options)+" ORDER BY order_item_id")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	rows, err := stmt.QueryContext(ctx, orderID)
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
 	orderItems := []*models.OrderItem{}
 	for rows.Next() {
-		orderItem := &models.OrderItem{}
-		err = rows.Scan(&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.Quantity, &orderItem.Price.Value, &orderItem.Price.Currency)
+		orderItem, err := scanOrderItem(rows)
 		if err != nil {
-			return nil, errors.Wrap(err, "prepare")
+			return nil, errors.Wrap(mapError(err), "scan error")
 		}
 		orderItems = append(orderItems, orderItem)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
 
 	return orderItems, nil
 }
 
-func (o orderItem) Save(orderItem *models.OrderItem) error {
-	stmt, err := o.db.Prepare("INSERT INTO order_items (order_id, product_id, quantity, price, currency) VALUES ($1, $2, $3, $4, $5) RETURNING order_item_id;")
+func (o orderItem) Save(ctx context.Context, orderItem *models.OrderItem) error {
+	if err := orderItem.Price.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate price")
+	}
+
+	stmt, err := o.stmts.prepare(ctx, "INSERT INTO order_items (order_id, product_id, quantity, price, currency, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING order_item_id;")
 	if err != nil {
-		return err
+		return mapError(err)
 	}
 
 	var lastInsertID int64
-	row := stmt.QueryRow(orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price.Value, orderItem.Price.Currency)
+	now := o.now()
+	row := stmt.QueryRowContext(ctx, orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price.Value, orderItem.Price.Currency, now)
 	if err := row.Scan(&lastInsertID); err != nil {
-		return err
+		return mapError(err)
 	}
 
 	orderItem.ID = lastInsertID
+	orderItem.CreatedAt, orderItem.UpdatedAt = now, now
 
 	return nil
 }
 
-func (o orderItem) SaveWithTransaction(tx *sql.Tx, orderItem *models.OrderItem) error {
-	stmt, err := tx.Prepare("INSERT INTO order_items (order_id, product_id, quantity, price, currency) VALUES ($1, $2, $3, $4, $5) RETURNING order_item_id;")
+func (o orderItem) SaveAll(ctx context.Context, orderItems []*models.OrderItem) error {
+	if len(orderItems) == 0 {
+		return nil
+	}
+	for i, orderItem := range orderItems {
+		if err := orderItem.Price.Validate(); err != nil {
+			return errors.Wrapf(validationError(err), "validate price of item %d", i)
+		}
+	}
+
+	// COPY does not return generated keys, so they are taken from the sequence beforehand
+	const nextIDs = "SELECT nextval(pg_get_serial_sequence('order_items', 'order_item_id')) FROM generate_series(1, $1)"
+	if err := o.stmts.warm(ctx, nextIDs); err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	ids := make([]int64, 0, len(orderItems))
+	now := o.now()
+	err := transact(ctx, o.db, repositories.TxOptions{}, o.retryPolicy, func(ctx context.Context) error {
+		ids = ids[:0]
+		stmt, err := o.stmts.prepare(ctx, nextIDs)
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare query error")
+		}
+
+		rows, err := stmt.QueryContext(ctx, len(orderItems))
+		if err != nil {
+			return errors.Wrap(mapError(err), "query error")
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return errors.Wrap(mapError(err), "scan error")
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return errors.Wrap(mapError(err), "rows error")
+		}
+		if len(ids) != len(orderItems) {
+			return errors.Errorf("got %d order item ids for %d items", len(ids), len(orderItems))
+		}
+
+		// a COPY statement lives only within the transaction, so it is not cached
+		copyStmt, err := txFromContext(ctx, o.db).tx.PrepareContext(ctx, pq.CopyIn("order_items", "order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at"))
+		if err != nil {
+			return errors.Wrap(mapError(err), "prepare copy error")
+		}
+		defer copyStmt.Close()
+
+		for i, orderItem := range orderItems {
+			_, err := copyStmt.ExecContext(ctx, ids[i], orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price.Value, orderItem.Price.Currency, now, now)
+			if err != nil {
+				return errors.Wrap(mapError(err), "copy error")
+			}
+		}
+		// an Exec without arguments flushes the buffered rows and ends COPY
+		if _, err := copyStmt.ExecContext(ctx); err != nil {
+			return errors.Wrap(mapError(err), "copy error")
+		}
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	var lastInsertID int64
-	row := stmt.QueryRow(orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price.Value, orderItem.Price.Currency)
-	if err := row.Scan(&lastInsertID); err != nil {
-		return err
+	for i, orderItem := range orderItems {
+		orderItem.ID = ids[i]
+		orderItem.CreatedAt, orderItem.UpdatedAt = now, now
 	}
 
-	orderItem.ID = lastInsertID
+	return nil
+}
+
+func (o orderItem) Update(ctx context.Context, orderItem *models.OrderItem) error {
+	if err := orderItem.Price.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate price")
+	}
+
+	stmt, err := o.stmts.prepare(ctx, "UPDATE order_items SET order_id=$2, product_id=$3, quantity=$4, price=$5, currency=$6, updated_at=$7 WHERE order_item_id=$1")
+	if err != nil {
+		return mapError(err)
+	}
+
+	now := o.now()
+	result, err := stmt.ExecContext(ctx, orderItem.ID, orderItem.OrderID, orderItem.ProductID, orderItem.Quantity, orderItem.Price.Value, orderItem.Price.Currency, now)
+	if err != nil {
+		return mapError(err)
+	}
+	if err := expectAffected(result); err != nil {
+		return mapError(err)
+	}
+
+	orderItem.UpdatedAt = now
+	return nil
+}
+
+func (o orderItem) Delete(ctx context.Context, orderItemID int64) error {
+	stmt, err := o.stmts.prepare(ctx, "DELETE FROM order_items WHERE order_item_id=$1")
+	if err != nil {
+		return mapError(err)
+	}
+
+	result, err := stmt.ExecContext(ctx, orderItemID)
+	if err != nil {
+		return mapError(err)
+	}
+
+	return mapError(expectAffected(result))
+}
+
+func (o orderItem) DeleteByOrderID(ctx context.Context, orderID models.OrderID) error {
+	stmt, err := o.stmts.prepare(ctx, "DELETE FROM order_items WHERE order_id=$1")
+	if err != nil {
+		return mapError(err)
+	}
 
+	_, err = stmt.ExecContext(ctx, orderID)
+	return mapError(err)
+}
+
+func (o orderItem) SoftDeleteByOrderID(ctx context.Context, orderID models.OrderID, deletedAt time.Time) error {
+	stmt, err := o.stmts.prepare(ctx, "UPDATE order_items SET deleted_at=$2, updated_at=$2 WHERE order_id=$1 AND deleted_at IS NULL")
+	if err != nil {
+		return mapError(err)
+	}
+
+	_, err = stmt.ExecContext(ctx, orderID, deletedAt)
+	return mapError(err)
+}
+
+func (o orderItem) RestoreByOrderID(ctx context.Context, orderID models.OrderID) error {
+	stmt, err := o.stmts.prepare(ctx, "UPDATE order_items SET deleted_at=NULL, updated_at=$2 WHERE order_id=$1 AND deleted_at IS NOT NULL")
+	if err != nil {
+		return mapError(err)
+	}
+
+	_, err = stmt.ExecContext(ctx, orderID, o.now())
+	return mapError(err)
+}
+
+// Close closes prepared statements
+func (o orderItem) Close() error {
+	return o.stmts.Close()
+}
+
+// orderIDArray converts order IDs to an array parameter for = ANY($n)
+func orderIDArray(orderIDs []models.OrderID) pq.Int64Array {
+	array := make(pq.Int64Array, len(orderIDs))
+	for i, orderID := range orderIDs {
+		array[i] = int64(orderID)
+	}
+	return array
+}
+
+// expectAffected reports sql.ErrNoRows when a statement changed nothing
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
@@ -3,11 +3,15 @@
 package postgresql
 
 import (
+	"context"
+	"github.com/lib/pq"
 	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/DATA-DOG/go-sqlmock.v2"
 	"testing"
+	"time"
 )
 
 func TestOrderItem_GetByOrderID(t *testing.T) {
@@ -20,15 +24,16 @@ func TestOrderItem_GetByOrderID(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency FROM order_items").ExpectQuery().
-			WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency"}).
-				AddRow(1, expectedOrderID, models.ProductID(2), 1, 3.0, "usd"))
+		mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}).
+				AddRow(1, expectedOrderID, models.ProductID(2), 1, "123456789012.3456", "usd", time.Now(), time.Now(), nil))
 
 		orderRepository := NewOrderItemRepository(db)
-		orderItems, err := orderRepository.GetByOrderID(expectedOrderID)
+		orderItems, err := orderRepository.GetByOrderID(context.Background(), expectedOrderID)
 		require.NoError(t, err)
 		require.NotEmpty(t, orderItems)
 		require.Equal(t, expectedOrderID, orderItems[0].OrderID)
+		require.Equal(t, "123456789012.3456", orderItems[0].Price.Value.String())
 	})
 
 	t.Run("errors", func(t *testing.T) {
@@ -41,22 +46,49 @@ func TestOrderItem_GetByOrderID(t *testing.T) {
 			}
 			defer db.Close()
 
-			mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency FROM order_items").ExpectQuery().WillReturnError(dummyError)
+			mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items").ExpectQuery().WillReturnError(dummyError)
 
 			orderRepository := NewOrderItemRepository(db)
-			orderItems, err := orderRepository.GetByOrderID(expectedOrderID)
+			orderItems, err := orderRepository.GetByOrderID(context.Background(), expectedOrderID)
 			require.Empty(t, orderItems)
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
 		})
 
+		t.Run("cancelled context aborts the query", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items").ExpectQuery().
+				WillDelayFor(time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			orderRepository := NewOrderItemRepository(db)
+			orderItems, err := orderRepository.GetByOrderID(ctx, expectedOrderID)
+			require.Empty(t, orderItems)
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), sqlmock.ErrCancelled)
+		})
+
 	})
 
 }
 
 func TestOrderItem_Save(t *testing.T) {
 	expectedOrderID := models.OrderID(1020)
-	expectedInput := &models.OrderItem{1, expectedOrderID, models.ProductID(2), 1, models.Money{3, models.USD}}
+	expectedInput := &models.OrderItem{
+		ID:        1,
+		OrderID:   expectedOrderID,
+		ProductID: models.ProductID(2),
+		Quantity:  1,
+		Price:     models.Money{Value: models.MustDecimalFromInt(3), Currency: models.USD},
+	}
 
 	t.Run("success", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -65,13 +97,13 @@ func TestOrderItem_Save(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING order_item_id`).
+		mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency, created_at, updated_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$6\) RETURNING order_item_id`).
 			ExpectQuery().
-			WithArgs(expectedOrderID, models.ProductID(2), 1, 3.0, "usd").
+			WithArgs(expectedOrderID, models.ProductID(2), 1, "3.0000", "usd", sqlmock.AnyArg()).
 			WillReturnRows(sqlmock.NewRows([]string{"order_item_id"}).AddRow(1))
 
 		orderRepository := NewOrderItemRepository(db)
-		err = orderRepository.Save(expectedInput)
+		err = orderRepository.Save(context.Background(), expectedInput)
 		require.NoError(t, err)
 	})
 
@@ -85,12 +117,12 @@ func TestOrderItem_Save(t *testing.T) {
 			}
 			defer db.Close()
 
-			mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING order_item_id`).
+			mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency, created_at, updated_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$6\) RETURNING order_item_id`).
 				ExpectQuery().
-				WithArgs(expectedOrderID, models.ProductID(2), 1, 3.0, "usd").WillReturnError(dummyError)
+				WithArgs(expectedOrderID, models.ProductID(2), 1, "3.0000", "usd", sqlmock.AnyArg()).WillReturnError(dummyError)
 
 			orderRepository := NewOrderItemRepository(db)
-			err = orderRepository.Save(expectedInput)
+			err = orderRepository.Save(context.Background(), expectedInput)
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
 		})
@@ -98,7 +130,7 @@ func TestOrderItem_Save(t *testing.T) {
 	})
 }
 
-func TestOrderItem_SaveWithTransaction(t *testing.T) {
+func TestOrderItem_SaveWithinTransaction(t *testing.T) {
 	expectedOrderID := models.OrderID(1020)
 
 	t.Run("success", func(t *testing.T) {
@@ -109,16 +141,299 @@ func TestOrderItem_SaveWithTransaction(t *testing.T) {
 		defer db.Close()
 
 		mock.ExpectBegin()
-		mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING order_item_id`).
+		mock.ExpectPrepare(`INSERT INTO order_items \(order_id, product_id, quantity, price, currency, created_at, updated_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$6\) RETURNING order_item_id`).
 			ExpectQuery().
-			WithArgs(expectedOrderID, models.ProductID(2), 1, 3.0, "usd").
+			WithArgs(expectedOrderID, models.ProductID(2), 1, "3.0000", "usd", sqlmock.AnyArg()).
 			WillReturnRows(sqlmock.NewRows([]string{"order_item_id"}).AddRow(1))
 
+		mock.ExpectCommit()
+
+		orderRepository := NewOrderItemRepository(db)
+		err = NewTxManager(db).WithinTransaction(context.Background(), func(ctx context.Context) error {
+			return orderRepository.Save(ctx, &models.OrderItem{
+				ID:        1,
+				OrderID:   expectedOrderID,
+				ProductID: models.ProductID(2),
+				Quantity:  1,
+				Price:     models.Money{Value: models.MustDecimalFromInt(3), Currency: models.USD},
+			})
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOrderItem_SaveAll(t *testing.T) {
+	orderItems := func() []*models.OrderItem {
+		return []*models.OrderItem{
+			{OrderID: 1020, ProductID: 2, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(3), Currency: models.USD}},
+			{OrderID: 1020, ProductID: 3, Quantity: 2, Price: models.Money{Value: models.MustDecimalFromInt(4), Currency: models.USD}},
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT nextval\(pg_get_serial_sequence\('order_items', 'order_item_id'\)\) FROM generate_series\(1, \$1\)`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT nextval`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(10).AddRow(11))
+		copyIn := mock.ExpectPrepare(`COPY "order_items" \("order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at"\) FROM STDIN`).WillBeClosed()
+		copyIn.ExpectExec().WithArgs(10, 1020, 2, 1, "3.0000", "usd", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		copyIn.ExpectExec().WithArgs(11, 1020, 3, 2, "4.0000", "usd", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		copyIn.ExpectExec().WithArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		orderItemRepository := NewOrderItemRepository(db)
+		items := orderItems()
+		err = orderItemRepository.SaveAll(context.Background(), items)
+		require.NoError(t, err)
+		require.Equal(t, int64(10), items[0].ID)
+		require.Equal(t, int64(11), items[1].ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("missing product", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT nextval`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT nextval`).
+				WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(10).AddRow(11))
+			copyIn := mock.ExpectPrepare(`COPY "order_items"`).WillBeClosed()
+			copyIn.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+			copyIn.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+			copyIn.ExpectExec().WillReturnError(&pq.Error{
+				Code:       "23503",
+				Constraint: "order_items_product_id_fkey",
+				Detail:     `Key (product_id)=(3) is not present in table "products".`,
+			})
+			mock.ExpectRollback()
+
+			orderItemRepository := NewOrderItemRepository(db)
+			items := orderItems()
+			err = orderItemRepository.SaveAll(context.Background(), items)
+			require.True(t, errors.Is(err, repositories.ErrInvalidReference))
+			var repositoryError *repositories.Error
+			require.True(t, errors.As(err, &repositoryError))
+			require.Equal(t, "product", repositoryError.Entity)
+			require.Zero(t, items[0].ID)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("invalid price is rejected before copy", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			orderItemRepository := NewOrderItemRepository(db)
+			items := orderItems()
+			items[1].Price.Currency = "zzz"
+			err = orderItemRepository.SaveAll(context.Background(), items)
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
+
+func TestOrderItem_Update(t *testing.T) {
+	expectedInput := &models.OrderItem{
+		ID:        5,
+		OrderID:   models.OrderID(1020),
+		ProductID: models.ProductID(2),
+		Quantity:  3,
+		Price:     models.Money{Value: models.MustParseDecimal("3.25"), Currency: models.USD},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`UPDATE order_items SET order_id=\$2, product_id=\$3, quantity=\$4, price=\$5, currency=\$6, updated_at=\$7 WHERE order_item_id=\$1`).
+			ExpectExec().
+			WithArgs(5, 1020, 2, 3, "3.2500", "usd", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		orderRepository := NewOrderItemRepository(db)
+		err = orderRepository.Update(context.Background(), expectedInput)
+		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("order item does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE order_items`).
+				ExpectExec().
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			orderRepository := NewOrderItemRepository(db)
+			err = orderRepository.Update(context.Background(), expectedInput)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestOrderItem_Delete(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`DELETE FROM order_items WHERE order_item_id=\$1`).
+			ExpectExec().
+			WithArgs(5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
 		orderRepository := NewOrderItemRepository(db)
-		tx, err := db.Begin()
+		err = orderRepository.Delete(context.Background(), 5)
 		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("order item does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`DELETE FROM order_items`).
+				ExpectExec().
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			orderRepository := NewOrderItemRepository(db)
+			err = orderRepository.Delete(context.Background(), 5)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestOrderItem_DeleteByOrderID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare(`DELETE FROM order_items WHERE order_id=\$1`).
+		ExpectExec().
+		WithArgs(1020).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	orderRepository := NewOrderItemRepository(db)
+	err = orderRepository.DeleteByOrderID(context.Background(), models.OrderID(1020))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
 
-		err = orderRepository.SaveWithTransaction(tx, &models.OrderItem{1, expectedOrderID, models.ProductID(2), 1, models.Money{3, models.USD}})
+func TestOrderItem_SoftDeleteByOrderID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	deletedAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	restoredAt := deletedAt.Add(time.Hour)
+	mock.ExpectPrepare(`UPDATE order_items SET deleted_at=\$2, updated_at=\$2 WHERE order_id=\$1 AND deleted_at IS NULL`).
+		ExpectExec().
+		WithArgs(1020, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectPrepare(`UPDATE order_items SET deleted_at=NULL, updated_at=\$2 WHERE order_id=\$1 AND deleted_at IS NOT NULL`).
+		ExpectExec().
+		WithArgs(1020, restoredAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	orderRepository := NewOrderItemRepository(db, WithClock(repositories.ClockFunc(func() time.Time { return restoredAt })))
+	require.NoError(t, orderRepository.SoftDeleteByOrderID(context.Background(), models.OrderID(1020), deletedAt))
+	require.NoError(t, orderRepository.RestoreByOrderID(context.Background(), models.OrderID(1020)))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOrderItem_GetByOrderIDs(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items WHERE order_id = ANY\(\$1\) AND deleted_at IS NULL ORDER BY order_id, order_item_id`).ExpectQuery().
+			WithArgs("{1,2,3}").
+			WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}).
+				AddRow(1, 1, 2, 1, "1.0000", "usd", time.Now(), time.Now(), nil).
+				AddRow(4, 1, 3, 2, "2.0000", "usd", time.Now(), time.Now(), nil).
+				AddRow(2, 3, 2, 1, "5.0000", "usd", time.Now(), time.Now(), nil))
+
+		orderItemRepository := NewOrderItemRepository(db)
+		orderItems, err := orderItemRepository.GetByOrderIDs(context.Background(), []models.OrderID{1, 2, 3})
 		require.NoError(t, err)
+		require.Len(t, orderItems, 3)
+		require.Len(t, orderItems[1], 2)
+		require.Equal(t, int64(4), orderItems[1][1].ID)
+		require.NotNil(t, orderItems[2])
+		require.Empty(t, orderItems[2])
+		require.Len(t, orderItems[3], 1)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("query returns an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items").ExpectQuery().WillReturnError(dummyError)
+
+			orderItemRepository := NewOrderItemRepository(db)
+			orderItems, err := orderItemRepository.GetByOrderIDs(context.Background(), []models.OrderID{1})
+			require.Nil(t, orderItems)
+			require.Equal(t, errors.Cause(err), dummyError)
+		})
+
+		t.Run("rows return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_item_id, order_id, product_id, quantity, price, currency, created_at, updated_at, deleted_at FROM order_items").ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}).
+					AddRow(1, 1, 2, 1, "1.0000", "usd", time.Now(), time.Now(), nil).
+					RowError(0, dummyError))
+
+			orderItemRepository := NewOrderItemRepository(db)
+			orderItems, err := orderItemRepository.GetByOrderIDs(context.Background(), []models.OrderID{1})
+			require.Nil(t, orderItems)
+			require.Equal(t, errors.Cause(err), dummyError)
+		})
 	})
 }
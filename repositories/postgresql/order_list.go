@@ -0,0 +1,134 @@
+package postgresql
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// sortColumns maps sort orders to columns, order_id breaks ties
+var sortColumns = map[repositories.OrderSort]string{
+	repositories.SortByCreatedAtDesc: "created_at",
+	repositories.SortByCreatedAtAsc:  "created_at",
+	repositories.SortByAmountDesc:    "amount",
+	repositories.SortByAmountAsc:     "amount",
+}
+
+// queryBuilder collects WHERE conditions with their positional arguments
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// arg adds an argument and returns its placeholder
+func (b *queryBuilder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *queryBuilder) where(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+func (o *order) List(ctx context.Context, filter repositories.OrderFilter, page repositories.Page, options ...repositories.QueryOption) (*repositories.OrderPage, error) {
+	if err := o.stmts.check(); err != nil {
+		return nil, err
+	}
+
+	page, err := page.Normalize()
+	if err != nil {
+		return nil, errors.Wrap(validationError(err), "validate page error")
+	}
+
+	b := &queryBuilder{}
+	if !repositories.NewQueryOptions(options...).IncludeDeleted {
+		b.where("deleted_at IS NULL")
+	}
+	if filter.CustomerID != 0 {
+		b.where("customer_id = " + b.arg(filter.CustomerID))
+	}
+	if filter.Currency != "" {
+		b.where("currency = " + b.arg(filter.Currency))
+	}
+	if filter.MinAmount != nil {
+		b.where("amount >= " + b.arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		b.where("amount <= " + b.arg(*filter.MaxAmount))
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make(pq.StringArray, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		b.where("status = ANY(" + b.arg(statuses) + ")")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		b.where("created_at >= " + b.arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		b.where("created_at < " + b.arg(filter.CreatedTo))
+	}
+
+	column := sortColumns[page.Sort]
+	direction, comparison := "ASC", ">"
+	if page.Sort.Descending() {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.Cursor != "" {
+		cursor, err := repositories.DecodeOrderCursor(page.Cursor, page.Sort)
+		if err != nil {
+			return nil, errors.Wrap(validationError(err), "decode cursor error")
+		}
+		var key interface{} = cursor.CreatedAt
+		if column == "amount" {
+			key = cursor.Amount
+		}
+		b.where("(" + column + ", order_id) " + comparison + " (" + b.arg(key) + ", " + b.arg(cursor.OrderID) + ")")
+	}
+
+	query := "SELECT " + orderColumns + " FROM orders"
+	if len(b.conditions) > 0 {
+		query += " WHERE " + strings.Join(b.conditions, " AND ")
+	}
+	// one extra row tells whether there is a next page
+	query += " ORDER BY " + column + " " + direction + ", order_id " + direction + " LIMIT " + b.arg(page.Limit+1)
+
+	// the query text depends on the filter, so it is not kept as a prepared statement
+	rows, err := conn(ctx, o.db).QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
+	orders := []*models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, errors.Wrap(mapError(err), "scan error")
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
+
+	result := &repositories.OrderPage{Orders: orders}
+	if len(orders) > page.Limit {
+		result.Orders = orders[:page.Limit]
+		result.NextCursor = repositories.NewOrderCursor(page.Sort, result.Orders[page.Limit-1]).Encode()
+	}
+
+	if err := o.loadItems(ctx, result.Orders, options); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,164 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+var orderListColumns = []string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}
+
+func TestOrder_List(t *testing.T) {
+	createdAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	filter := repositories.OrderFilter{
+		CustomerID: 5,
+		Statuses:   []models.OrderStatus{models.OrderStatusPaid, models.OrderStatusPlaced},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT order_id, COALESCE\(public_id, order_id::text\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders WHERE deleted_at IS NULL `+
+			`AND customer_id = \$1 AND status = ANY\(\$2\) ORDER BY created_at DESC, order_id DESC LIMIT \$3`).
+			WithArgs(5, `{"paid","placed"}`, 3).
+			WillReturnRows(sqlmock.NewRows(orderListColumns).
+				AddRow(9, "9", 5, "3.0000", "usd", "paid", createdAt, createdAt, 1, nil).
+				AddRow(8, "8", 5, "2.0000", "usd", "placed", createdAt, createdAt, 1, nil).
+				AddRow(7, "7", 5, "1.0000", "usd", "paid", createdAt, createdAt, 1, nil))
+		mock.ExpectQuery(`SELECT (.+) FROM orders WHERE deleted_at IS NULL AND customer_id = \$1 AND status = ANY\(\$2\) `+
+			`AND \(created_at, order_id\) < \(\$3, \$4\) ORDER BY created_at DESC, order_id DESC LIMIT \$5`).
+			WithArgs(5, `{"paid","placed"}`, createdAt, 8, 3).
+			WillReturnRows(sqlmock.NewRows(orderListColumns).
+				AddRow(7, "7", 5, "1.0000", "usd", "paid", createdAt, createdAt, 1, nil))
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderIDs(gomock.Any(), []models.OrderID{9, 8}).
+			Return(map[models.OrderID][]*models.OrderItem{9: {{ID: 1, OrderID: 9}}, 8: {}}, nil)
+		mockOrderItemRepository.EXPECT().GetByOrderIDs(gomock.Any(), []models.OrderID{7}).
+			Return(map[models.OrderID][]*models.OrderItem{}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		page, err := orderRepository.List(context.Background(), filter, repositories.Page{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 2)
+		require.Equal(t, models.OrderID(8), page.Orders[1].ID)
+		require.Len(t, page.Orders[0].Items, 1)
+		require.Empty(t, page.Orders[1].Items)
+		require.Equal(t, createdAt, page.Orders[1].CreatedAt)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = orderRepository.List(context.Background(), filter, repositories.Page{Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 1)
+		require.NotNil(t, page.Orders[0].Items)
+		require.Empty(t, page.NextCursor)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("amount range", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		minAmount, maxAmount := models.MustDecimalFromInt(1), models.MustDecimalFromInt(10)
+		mock.ExpectQuery(`SELECT (.+) FROM orders WHERE deleted_at IS NULL AND currency = \$1 AND amount >= \$2 AND amount <= \$3 `+
+			`ORDER BY amount ASC, order_id ASC LIMIT \$4`).
+			WithArgs("usd", "1.0000", "10.0000", repositories.DefaultPageLimit+1).
+			WillReturnRows(sqlmock.NewRows(orderListColumns))
+
+		orderRepository := NewOrderRepository(db, nil)
+		page, err := orderRepository.List(context.Background(), repositories.OrderFilter{
+			Currency:  models.USD,
+			MinAmount: &minAmount,
+			MaxAmount: &maxAmount,
+		}, repositories.Page{Sort: repositories.SortByAmountAsc})
+		require.NoError(t, err)
+		require.Empty(t, page.Orders)
+	})
+
+	t.Run("include deleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(`SELECT (.+) FROM orders WHERE customer_id = \$1 ORDER BY created_at DESC, order_id DESC LIMIT \$2`).
+			WithArgs(5, repositories.DefaultPageLimit+1).
+			WillReturnRows(sqlmock.NewRows(orderListColumns).
+				AddRow(7, "7", 5, "1.0000", "usd", "paid", createdAt, createdAt, 2, createdAt))
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderIDs(gomock.Any(), []models.OrderID{7}, gomock.Any()).
+			Return(map[models.OrderID][]*models.OrderItem{}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		page, err := orderRepository.List(context.Background(), repositories.OrderFilter{CustomerID: 5}, repositories.Page{}, repositories.IncludeDeleted())
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 1)
+		require.Equal(t, createdAt, *page.Orders[0].DeletedAt)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("cursor of another sort order", func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			cursor := repositories.NewOrderCursor(repositories.SortByAmountAsc, &models.Order{ID: 1}).Encode()
+
+			orderRepository := NewOrderRepository(db, nil)
+			_, err = orderRepository.List(context.Background(), filter, repositories.Page{Cursor: cursor})
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+		})
+
+		t.Run("malformed cursor", func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			orderRepository := NewOrderRepository(db, nil)
+			_, err = orderRepository.List(context.Background(), filter, repositories.Page{Cursor: "%%%"})
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+		})
+
+		t.Run("repository is closed", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			orderRepository := NewOrderRepository(db, nil)
+			require.NoError(t, orderRepository.Close())
+			_, err = orderRepository.List(context.Background(), filter, repositories.Page{})
+			require.True(t, errors.Is(err, repositories.ErrClosed))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
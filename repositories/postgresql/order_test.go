@@ -3,6 +3,7 @@
 package postgresql
 
 import (
+	"context"
 	"github.com/golang/mock/gomock"
 	"github.com/netology/dao-pattern/models"
 	"github.com/netology/dao-pattern/repositories"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"gopkg.in/DATA-DOG/go-sqlmock.v2"
 	"testing"
+	"time"
 )
 
 func TestOrder_Save(t *testing.T) {
@@ -22,40 +24,122 @@ func TestOrder_Save(t *testing.T) {
 		}
 		defer db.Close()
 
+		now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+		mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`)
 		mock.ExpectBegin()
-		mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency\) VALUES \(\$1, \$2, \$3\) RETURNING order_id`).
-			ExpectQuery().
-			WithArgs(1, float64(1), "usd").
-			WillReturnRows(sqlmock.NewRows([]string{"order_id"}).AddRow(expectedID))
+		mock.ExpectQuery(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`).
+			WithArgs(1, "1.0000", "usd", "draft", now, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(expectedID, 1))
 		mock.ExpectCommit()
 
 		ctrl := gomock.NewController(t)
 		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
-		mockOrderItemRepository.EXPECT().SaveWithTransaction(gomock.Any(), gomock.Any()).Return(nil)
+		mockOrderItemRepository.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
 
-		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository, WithClock(repositories.ClockFunc(func() time.Time { return now })))
 
 		orderEntity := &models.Order{
 			CustomerID: models.CustomerID(1),
-			Amount:     models.Money{1, models.USD},
+			Amount:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
 			Items: []*models.OrderItem{
 				{
 					ProductID: 1,
 					Quantity:  1,
-					Price:     models.Money{8, models.USD},
+					Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
 				},
 			},
 		}
-		err = orderRepository.Save(orderEntity)
+		err = orderRepository.Save(context.Background(), orderEntity)
 		require.NoError(t, err)
 		require.Equal(t, expectedID, orderEntity.ID)
+		require.Equal(t, "123", orderEntity.PublicID)
+		require.Equal(t, now, orderEntity.CreatedAt)
+		require.Equal(t, now, orderEntity.UpdatedAt)
+
+		ctrl.Finish()
+	})
+
+	t.Run("items above the bulk insert threshold are saved at once", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`INSERT INTO orders`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO orders`).
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(123, 1))
+		mock.ExpectCommit()
+
+		orderEntity := &models.Order{
+			CustomerID: models.CustomerID(1),
+			Amount:     models.Money{Value: models.MustDecimalFromInt(2), Currency: models.USD},
+			Items: []*models.OrderItem{
+				{ProductID: 1, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}},
+				{ProductID: 2, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}},
+			},
+		}
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().SaveAll(gomock.Any(), orderEntity.Items).Return(nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository, WithBulkInsertThreshold(1))
+		err = orderRepository.Save(context.Background(), orderEntity)
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(123), orderEntity.Items[1].OrderID)
+		require.NoError(t, mock.ExpectationsWereMet())
 
 		ctrl.Finish()
 	})
 
+	t.Run("generated public id", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`INSERT INTO orders`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO orders`).
+			WithArgs(1, "1.0000", "usd", "draft", sqlmock.AnyArg(), "01ARYZ6S41TSV4RRFFQ69G5FAV").
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(123, 1))
+		mock.ExpectCommit()
+
+		generator := repositories.IDGeneratorFunc(func() (string, error) {
+			return "01ARYZ6S41TSV4RRFFQ69G5FAV", nil
+		})
+		orderRepository := NewOrderRepository(db, nil, WithIDGenerator(generator))
+		orderEntity := &models.Order{CustomerID: 1, Amount: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}}
+		err = orderRepository.Save(context.Background(), orderEntity)
+		require.NoError(t, err)
+		require.Equal(t, "01ARYZ6S41TSV4RRFFQ69G5FAV", orderEntity.PublicID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("errors", func(t *testing.T) {
 		dummyError := errors.New("dummy-error")
 
+		t.Run("id generator returns an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`INSERT INTO orders`)
+
+			generator := repositories.IDGeneratorFunc(func() (string, error) {
+				return "", dummyError
+			})
+			orderRepository := NewOrderRepository(db, nil, WithIDGenerator(generator))
+			err = orderRepository.Save(context.Background(), &models.Order{Amount: models.Money{Currency: models.USD}})
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
 		t.Run("begin transaction return an error", func(t *testing.T) {
 			db, mock, err := sqlmock.New()
 			if err != nil {
@@ -63,14 +147,40 @@ func TestOrder_Save(t *testing.T) {
 			}
 			defer db.Close()
 
+			mock.ExpectPrepare(`INSERT INTO orders`)
 			mock.ExpectBegin().WillReturnError(dummyError)
 
 			orderRepository := NewOrderRepository(db, nil)
-			err = orderRepository.Save(&models.Order{})
+			err = orderRepository.Save(context.Background(), &models.Order{Amount: models.Money{Currency: models.USD}})
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
 		})
 
+		t.Run("unknown currency is rejected before insert", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.Save(context.Background(), &models.Order{
+				CustomerID: models.CustomerID(1),
+				Amount:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
+				Items: []*models.OrderItem{
+					{
+						ProductID: 1,
+						Quantity:  1,
+						Price:     models.Money{Value: models.MustDecimalFromInt(1), Currency: "zzz"},
+					},
+				},
+			})
+			require.Error(t, err)
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+			require.True(t, errors.Is(err, models.ErrUnknownCurrency))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
 		t.Run("prepare and query return an error", func(t *testing.T) {
 			db, mock, err := sqlmock.New()
 			if err != nil {
@@ -78,14 +188,16 @@ func TestOrder_Save(t *testing.T) {
 			}
 			defer db.Close()
 
+			mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`)
 			mock.ExpectBegin()
-			mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency\) VALUES \(\$1, \$2, \$3\) RETURNING order_id`).ExpectQuery().
-				WithArgs(1, float64(1), "usd").WillReturnError(dummyError)
+			mock.ExpectQuery(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`).
+				WithArgs(1, "1.0000", "usd", "draft", sqlmock.AnyArg(), nil).WillReturnError(dummyError)
+			mock.ExpectRollback()
 
 			orderRepository := NewOrderRepository(db, nil)
-			err = orderRepository.Save(&models.Order{
+			err = orderRepository.Save(context.Background(), &models.Order{
 				CustomerID: models.CustomerID(1),
-				Amount:     models.Money{1, models.USD},
+				Amount:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
 			})
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
@@ -100,38 +212,246 @@ func TestOrder_Save(t *testing.T) {
 			}
 			defer db.Close()
 
+			mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`)
 			mock.ExpectBegin()
-			mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency\) VALUES \(\$1, \$2, \$3\) RETURNING order_id`).
-				ExpectQuery().
-				WithArgs(1, float64(1), "usd").
-				WillReturnRows(sqlmock.NewRows([]string{"order_id"}).AddRow(expectedID))
+			mock.ExpectQuery(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`).
+				WithArgs(1, "1.0000", "usd", "draft", sqlmock.AnyArg(), nil).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(expectedID, 1))
 			mock.ExpectRollback()
 
 			ctrl := gomock.NewController(t)
 			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
-			mockOrderItemRepository.EXPECT().SaveWithTransaction(gomock.Any(), gomock.Any()).Return(dummyError)
+			mockOrderItemRepository.EXPECT().Save(gomock.Any(), gomock.Any()).Return(dummyError)
 
 			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
 
 			orderEntity := &models.Order{
 				CustomerID: models.CustomerID(1),
-				Amount:     models.Money{1, models.USD},
+				Amount:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
 				Items: []*models.OrderItem{
 					{
 						ProductID: 1,
 						Quantity:  1,
-						Price:     models.Money{8, models.USD},
+						Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
 					},
 				},
 			}
-			err = orderRepository.Save(orderEntity)
+			err = orderRepository.Save(context.Background(), orderEntity)
 
 			ctrl.Finish()
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
 		})
+
+		t.Run("commit fails and the order is saved again", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`INSERT INTO orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(123, 1))
+			mock.ExpectCommit().WillReturnError(dummyError)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(124, 1))
+			mock.ExpectCommit()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			itemID := int64(0)
+			mockOrderItemRepository.EXPECT().Save(gomock.Any(), gomock.Any()).Do(func(ctx context.Context, item *models.OrderItem) {
+				itemID++
+				item.ID = itemID
+			}).Return(nil).Times(2)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+
+			orderEntity := &models.Order{
+				CustomerID: models.CustomerID(1),
+				Amount:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+				Items: []*models.OrderItem{
+					{ProductID: 1, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD}},
+				},
+			}
+			err = orderRepository.Save(context.Background(), orderEntity)
+			require.Equal(t, dummyError, errors.Cause(err))
+			require.Zero(t, orderEntity.ID)
+			require.Empty(t, orderEntity.PublicID)
+			require.Zero(t, orderEntity.Version)
+			require.True(t, orderEntity.CreatedAt.IsZero())
+			require.Zero(t, orderEntity.Items[0].ID)
+			require.Zero(t, orderEntity.Items[0].OrderID)
+
+			err = orderRepository.Save(context.Background(), orderEntity)
+			require.NoError(t, err)
+			require.Equal(t, models.OrderID(124), orderEntity.ID)
+			require.Equal(t, "124", orderEntity.PublicID)
+			require.Equal(t, int64(2), orderEntity.Items[0].ID)
+			require.Equal(t, models.OrderID(124), orderEntity.Items[0].OrderID)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+
+		t.Run("cancelled context aborts the query and rolls back", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO orders \(customer_id, amount, currency, status, created_at, updated_at, public_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$5, \$6\) RETURNING order_id, version`).
+				WithArgs(1, "1.0000", "usd", "draft", sqlmock.AnyArg(), nil).
+				WillDelayFor(time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(123, 1))
+			mock.ExpectRollback()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.Save(ctx, &models.Order{
+				CustomerID: models.CustomerID(1),
+				Amount:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
+			})
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), sqlmock.ErrCancelled)
+
+			// database/sql rolls back a transaction with a done context asynchronously
+			for i := 0; i < 100 && db.Stats().InUse > 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+}
+
+func TestOrder_SaveIdempotent(t *testing.T) {
+	now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := WithClock(repositories.ClockFunc(func() time.Time { return now }))
+	orderEntity := func() *models.Order {
+		return &models.Order{CustomerID: 1, Amount: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}}
+	}
+	hash := repositories.OrderRequestHash(orderEntity())
+	take := `INSERT INTO idempotency_keys \(key, request_hash, created_at\) VALUES \(\$1, \$2, \$3\) ON CONFLICT \(key\) DO UPDATE (.+) WHERE idempotency_keys.created_at < \$4 RETURNING key`
+	bind := `UPDATE idempotency_keys SET order_id=\$2 WHERE key=\$1`
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(take)
+		mock.ExpectPrepare(bind)
+		mock.ExpectPrepare(`INSERT INTO orders`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(take).
+			WithArgs("checkout-1", hash, now, now.Add(-repositories.DefaultIdempotencyKeyTTL)).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("checkout-1"))
+		mock.ExpectQuery(`INSERT INTO orders`).
+			WithArgs(1, "1.0000", "usd", "draft", now, nil).
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(123, 1))
+		mock.ExpectExec(bind).
+			WithArgs("checkout-1", 123).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		orderRepository := NewOrderRepository(db, nil, clock)
+		order := orderEntity()
+		err = orderRepository.SaveIdempotent(context.Background(), "checkout-1", order)
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(123), order.ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(take)
+		mock.ExpectPrepare(bind)
+		mock.ExpectPrepare(`INSERT INTO orders`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(take).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}))
+		mock.ExpectPrepare(`SELECT request_hash, order_id FROM idempotency_keys WHERE key=\$1`).ExpectQuery().
+			WithArgs("checkout-1").
+			WillReturnRows(sqlmock.NewRows([]string{"request_hash", "order_id"}).AddRow(hash, 123))
+		mock.ExpectPrepare(`SELECT (.+) FROM orders WHERE order_id=\$1$`).ExpectQuery().
+			WithArgs(123).
+			WillReturnRows(sqlmock.NewRows(orderListColumns).AddRow(123, "123", 1, "1.0000", "usd", "draft", now, now, 1, nil))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(123), gomock.Any()).Return([]*models.OrderItem{}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository, clock)
+		order := orderEntity()
+		err = orderRepository.SaveIdempotent(context.Background(), "checkout-1", order)
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(123), order.ID)
+		require.Equal(t, now, order.CreatedAt)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("key of another order", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(take)
+			mock.ExpectPrepare(bind)
+			mock.ExpectPrepare(`INSERT INTO orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(take).
+				WillReturnRows(sqlmock.NewRows([]string{"key"}))
+			mock.ExpectPrepare(`SELECT request_hash, order_id FROM idempotency_keys`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"request_hash", "order_id"}).AddRow("another-hash", 123))
+			mock.ExpectRollback()
+
+			orderRepository := NewOrderRepository(db, nil, clock)
+			err = orderRepository.SaveIdempotent(context.Background(), "checkout-1", orderEntity())
+			require.True(t, errors.Is(err, repositories.ErrIdempotencyKeyReused))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
 	})
+}
+
+func TestOrder_PurgeIdempotencyKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
 
+	now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectPrepare(`DELETE FROM idempotency_keys WHERE created_at < \$1`).
+		ExpectExec().
+		WithArgs(now.Add(-time.Hour)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	orderRepository := NewOrderRepository(db, nil, WithIdempotencyKeyTTL(time.Hour), WithClock(repositories.ClockFunc(func() time.Time { return now })))
+	purged, err := orderRepository.PurgeIdempotencyKeys(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(4), purged)
 }
 
 func TestOrder_GetByID(t *testing.T) {
@@ -144,18 +464,44 @@ func TestOrder_GetByID(t *testing.T) {
 		}
 		defer db.Close()
 
-		mock.ExpectPrepare("SELECT order_id, customer_id, amount, currency FROM orders").ExpectQuery().
-			WillReturnRows(sqlmock.NewRows([]string{"order_id", "customer_id", "amount", "currency"}).AddRow(expectedOrderID, 2, 3.0, "usd"))
+		mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}).AddRow(expectedOrderID, "1", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 1, nil))
 
 		ctrl := gomock.NewController(t)
 		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
 
-		mockOrderItemRepository.EXPECT().GetByOrderID(expectedOrderID).Return([]*models.OrderItem{}, nil)
+		mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), expectedOrderID).Return([]*models.OrderItem{}, nil)
 
 		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
-		order, err := orderRepository.GetByID(expectedOrderID)
+		order, err := orderRepository.GetByID(context.Background(), expectedOrderID)
 		require.NoError(t, err)
 		require.NotNil(t, order)
+		require.Equal(t, models.OrderStatusPaid, order.Status)
+
+		ctrl.Finish()
+	})
+
+	t.Run("include deleted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		deletedAt := time.Now()
+		mock.ExpectPrepare(`SELECT (.+) FROM orders WHERE order_id=\$1$`).ExpectQuery().
+			WillReturnRows(sqlmock.NewRows(orderListColumns).AddRow(expectedOrderID, "1", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 2, deletedAt))
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), expectedOrderID, gomock.Any()).Return([]*models.OrderItem{}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		order, err := orderRepository.GetByID(context.Background(), expectedOrderID, repositories.IncludeDeleted())
+		require.NoError(t, err)
+		require.NotNil(t, order.DeletedAt)
+		require.True(t, deletedAt.Equal(*order.DeletedAt))
+		require.NoError(t, mock.ExpectationsWereMet())
 
 		ctrl.Finish()
 	})
@@ -170,14 +516,738 @@ func TestOrder_GetByID(t *testing.T) {
 			}
 			defer db.Close()
 
-			mock.ExpectPrepare("SELECT order_id, customer_id, amount, currency FROM orders").ExpectQuery().WillReturnError(dummyError)
+			mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().WillReturnError(dummyError)
 
 			orderRepository := NewOrderRepository(db, nil)
-			order, err := orderRepository.GetByID(expectedOrderID)
+			order, err := orderRepository.GetByID(context.Background(), expectedOrderID)
 			require.Nil(t, order)
 			require.Error(t, err)
 			require.Equal(t, errors.Cause(err), dummyError)
 		})
 
+		t.Run("order does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}))
+
+			orderRepository := NewOrderRepository(db, nil)
+			order, err := orderRepository.GetByID(context.Background(), expectedOrderID)
+			require.Nil(t, order)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+
+		t.Run("cancelled context aborts the query", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().
+				WillDelayFor(time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}).AddRow(expectedOrderID, "1", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 1, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			orderRepository := NewOrderRepository(db, nil)
+			order, err := orderRepository.GetByID(ctx, expectedOrderID)
+			require.Nil(t, order)
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), sqlmock.ErrCancelled)
+		})
+
+	})
+}
+
+func TestOrder_GetByPublicID(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT (.+) FROM orders WHERE COALESCE\(public_id, order_id::text\)=\$1 AND deleted_at IS NULL`).ExpectQuery().
+			WithArgs("01ARYZ6S41TSV4RRFFQ69G5FAV").
+			WillReturnRows(sqlmock.NewRows(orderListColumns).AddRow(5, "01ARYZ6S41TSV4RRFFQ69G5FAV", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 1, nil))
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(5)).Return([]*models.OrderItem{}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		order, err := orderRepository.GetByPublicID(context.Background(), "01ARYZ6S41TSV4RRFFQ69G5FAV")
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(5), order.ID)
+		require.Equal(t, "01ARYZ6S41TSV4RRFFQ69G5FAV", order.PublicID)
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("order does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT (.+) FROM orders`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows(orderListColumns))
+
+			orderRepository := NewOrderRepository(db, nil)
+			order, err := orderRepository.GetByPublicID(context.Background(), "missing")
+			require.Nil(t, order)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestOrder_GetByIDs(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT order_id, COALESCE\(public_id, order_id::text\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders WHERE order_id = ANY\(\$1\)`).ExpectQuery().
+			WithArgs("{3,1,2,3}").
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}).
+				AddRow(1, "1", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 1, nil).
+				AddRow(3, "3", 2, "1.0000", "usd", "draft", time.Now(), time.Now(), 1, nil))
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+
+		mockOrderItemRepository.EXPECT().GetByOrderIDs(gomock.Any(), []models.OrderID{3, 1}).
+			Return(map[models.OrderID][]*models.OrderItem{1: {{ID: 5, OrderID: 1}}, 3: {}}, nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		orders, err := orderRepository.GetByIDs(context.Background(), []models.OrderID{3, 1, 2, 3})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, models.OrderID(3), orders[0].ID)
+		require.Empty(t, orders[0].Items)
+		require.Equal(t, models.OrderID(1), orders[1].ID)
+		require.Len(t, orders[1].Items, 1)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
 	})
+
+	t.Run("no ids", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		orderRepository := NewOrderRepository(db, nil)
+		orders, err := orderRepository.GetByIDs(context.Background(), nil)
+		require.NoError(t, err)
+		require.Empty(t, orders)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("query returns an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().WillReturnError(dummyError)
+
+			orderRepository := NewOrderRepository(db, nil)
+			orders, err := orderRepository.GetByIDs(context.Background(), []models.OrderID{1})
+			require.Nil(t, orders)
+			require.Equal(t, errors.Cause(err), dummyError)
+		})
+
+		t.Run("items return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare("SELECT order_id, COALESCE\\(public_id, order_id::text\\), customer_id, amount, currency, status, created_at, updated_at, version, deleted_at FROM orders").ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "public_id", "customer_id", "amount", "currency", "status", "created_at", "updated_at", "version", "deleted_at"}).
+					AddRow(1, "1", 2, "3.0000", "usd", "paid", time.Now(), time.Now(), 1, nil))
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().GetByOrderIDs(gomock.Any(), gomock.Any()).Return(nil, dummyError)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			orders, err := orderRepository.GetByIDs(context.Background(), []models.OrderID{1})
+			require.Nil(t, orders)
+			require.Equal(t, errors.Cause(err), dummyError)
+
+			ctrl.Finish()
+		})
+	})
+}
+
+func TestOrder_Update(t *testing.T) {
+	orderEntity := func() *models.Order {
+		return &models.Order{
+			ID:         models.OrderID(7),
+			CustomerID: models.CustomerID(1),
+			Amount:     models.Money{Value: models.MustDecimalFromInt(10), Currency: models.USD},
+			Version:    1,
+			Items: []*models.OrderItem{
+				{ID: 1, ProductID: 1, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(2), Currency: models.USD}},
+				{ID: 2, ProductID: 2, Quantity: 5, Price: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}},
+				{ProductID: 3, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(3), Currency: models.USD}},
+			},
+		}
+	}
+	storedItems := func() []*models.OrderItem {
+		return []*models.OrderItem{
+			{ID: 1, OrderID: 7, ProductID: 1, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(2), Currency: models.USD}},
+			{ID: 2, OrderID: 7, ProductID: 2, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD}},
+			{ID: 3, OrderID: 7, ProductID: 4, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(9), Currency: models.USD}},
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := `UPDATE orders SET customer_id=\$2, amount=\$3, currency=\$4, updated_at=\$6, version=version\+1 WHERE order_id=\$1 AND version=\$5 AND deleted_at IS NULL RETURNING version`
+		mock.ExpectPrepare(query)
+		mock.ExpectBegin()
+		mock.ExpectQuery(query).
+			WithArgs(7, 1, "10.0000", "usd", 1, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		order := orderEntity()
+		gomock.InOrder(
+			mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(7)).Return(storedItems(), nil),
+			mockOrderItemRepository.EXPECT().Update(gomock.Any(), order.Items[1]).Return(nil),
+			mockOrderItemRepository.EXPECT().Save(gomock.Any(), order.Items[2]).Return(nil),
+			mockOrderItemRepository.EXPECT().Delete(gomock.Any(), int64(3)).Return(nil),
+		)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		err = orderRepository.Update(context.Background(), order)
+		require.NoError(t, err)
+		require.Equal(t, models.OrderID(7), order.Items[2].OrderID)
+		require.Equal(t, int64(2), order.Version)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("update again after a failed commit", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`UPDATE orders`)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE orders`).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		mock.ExpectCommit().WillReturnError(errors.New("dummy-error"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE orders`).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(7)).Return(storedItems(), nil).Times(2)
+		mockOrderItemRepository.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+		mockOrderItemRepository.EXPECT().Delete(gomock.Any(), int64(3)).Return(nil).Times(2)
+		itemID := int64(3)
+		mockOrderItemRepository.EXPECT().Save(gomock.Any(), gomock.Any()).Do(func(ctx context.Context, item *models.OrderItem) {
+			itemID++
+			item.ID = itemID
+		}).Return(nil).Times(2)
+
+		order := orderEntity()
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		err = orderRepository.Update(context.Background(), order)
+		require.Error(t, err)
+		require.Zero(t, order.Items[2].ID)
+		require.Zero(t, order.Items[2].OrderID)
+		require.Equal(t, int64(1), order.Version)
+
+		// the added item is inserted again instead of failing as an item of another order
+		err = orderRepository.Update(context.Background(), order)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), order.Items[2].ID)
+		require.Equal(t, int64(2), order.Version)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("order does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectPrepare(`SELECT version FROM orders WHERE order_id=\$1`).ExpectQuery().
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectRollback()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.Update(context.Background(), orderEntity())
+			require.Error(t, err)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("stale version", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectPrepare(`SELECT version FROM orders`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+			mock.ExpectRollback()
+
+			order := orderEntity()
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.Update(context.Background(), order)
+			require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+			require.Contains(t, err.Error(), "is at version 3, not 1")
+			require.Equal(t, int64(1), order.Version)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("item of another order", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(7)).Return(storedItems()[2:], nil)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.Update(context.Background(), orderEntity())
+			require.Error(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+
+		t.Run("orderitem repository return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().GetByOrderID(gomock.Any(), models.OrderID(7)).Return(storedItems(), nil)
+			mockOrderItemRepository.EXPECT().Update(gomock.Any(), gomock.Any()).Return(dummyError)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.Update(context.Background(), orderEntity())
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+	})
+}
+
+func TestOrder_Delete(t *testing.T) {
+	expectedOrderID := models.OrderID(7)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`DELETE FROM orders WHERE order_id=\$1 AND version=\$2`)
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM orders WHERE order_id=\$1 AND version=\$2`).
+			WithArgs(expectedOrderID, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().DeleteByOrderID(gomock.Any(), expectedOrderID).Return(nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		err = orderRepository.Delete(context.Background(), expectedOrderID, 1)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("order does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`DELETE FROM orders`)
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM orders`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT version FROM orders`).ExpectQuery().
+				WithArgs(expectedOrderID).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().DeleteByOrderID(gomock.Any(), expectedOrderID).Return(nil)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.Delete(context.Background(), expectedOrderID, 1)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+
+		t.Run("stale version", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`DELETE FROM orders`)
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM orders`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT version FROM orders`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().DeleteByOrderID(gomock.Any(), expectedOrderID).Return(nil)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.Delete(context.Background(), expectedOrderID, 1)
+			require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+
+		t.Run("orderitem repository return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`DELETE FROM orders`)
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().DeleteByOrderID(gomock.Any(), expectedOrderID).Return(dummyError)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.Delete(context.Background(), expectedOrderID, 1)
+			require.Error(t, err)
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+	})
+}
+
+func TestOrder_SoftDelete(t *testing.T) {
+	expectedOrderID := models.OrderID(7)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		now := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+		query := `UPDATE orders SET deleted_at=\$3, updated_at=\$3, version=version\+1 WHERE order_id=\$1 AND version=\$2 AND deleted_at IS NULL`
+		mock.ExpectPrepare(query)
+		mock.ExpectBegin()
+		mock.ExpectExec(query).
+			WithArgs(expectedOrderID, 1, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().SoftDeleteByOrderID(gomock.Any(), expectedOrderID, now).Return(nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository, WithClock(repositories.ClockFunc(func() time.Time { return now })))
+		err = orderRepository.SoftDelete(context.Background(), expectedOrderID, 1)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		query := `UPDATE orders SET deleted_at=NULL, updated_at=\$3, version=version\+1 WHERE order_id=\$1 AND version=\$2 AND deleted_at IS NOT NULL`
+		mock.ExpectPrepare(query)
+		mock.ExpectBegin()
+		mock.ExpectExec(query).
+			WithArgs(expectedOrderID, 2, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		ctrl := gomock.NewController(t)
+		mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+		mockOrderItemRepository.EXPECT().RestoreByOrderID(gomock.Any(), expectedOrderID).Return(nil)
+
+		orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+		err = orderRepository.Restore(context.Background(), expectedOrderID, 2)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		ctrl.Finish()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("order is already deleted", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE orders`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT version FROM orders WHERE order_id=\$1 AND deleted_at IS NULL`).ExpectQuery().
+				WithArgs(expectedOrderID).
+				WillReturnRows(sqlmock.NewRows([]string{"version"}))
+			mock.ExpectRollback()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.SoftDelete(context.Background(), expectedOrderID, 1)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("stale version on restore", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE orders`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT version FROM orders WHERE order_id=\$1 AND deleted_at IS NOT NULL`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+			mock.ExpectRollback()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.Restore(context.Background(), expectedOrderID, 2)
+			require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("orderitem repository return an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`)
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE orders`).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectRollback()
+
+			ctrl := gomock.NewController(t)
+			mockOrderItemRepository := repositories.NewMockOrderItemRepository(ctrl)
+			mockOrderItemRepository.EXPECT().SoftDeleteByOrderID(gomock.Any(), expectedOrderID, gomock.Any()).Return(dummyError)
+
+			orderRepository := NewOrderRepository(db, mockOrderItemRepository)
+			err = orderRepository.SoftDelete(context.Background(), expectedOrderID, 1)
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			ctrl.Finish()
+		})
+	})
+}
+
+func TestOrder_TransitionStatus(t *testing.T) {
+	expectedOrderID := models.OrderID(7)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`UPDATE orders SET status=\$3, updated_at=\$4, version=version\+1 WHERE order_id=\$1 AND status=\$2`).
+			ExpectExec().
+			WithArgs(expectedOrderID, "placed", "paid", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		orderRepository := NewOrderRepository(db, nil)
+		err = orderRepository.TransitionStatus(context.Background(), expectedOrderID, models.OrderStatusPlaced, models.OrderStatusPaid)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("illegal transition", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.TransitionStatus(context.Background(), expectedOrderID, models.OrderStatusDraft, models.OrderStatusShipped)
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+			require.True(t, errors.Is(err, models.ErrIllegalTransition))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("stale status", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT status FROM orders WHERE order_id=\$1`).
+				ExpectQuery().
+				WithArgs(expectedOrderID).
+				WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("cancelled"))
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.TransitionStatus(context.Background(), expectedOrderID, models.OrderStatusPlaced, models.OrderStatusPaid)
+			require.True(t, errors.Is(err, repositories.ErrStaleStatus))
+			require.Contains(t, err.Error(), "is cancelled, not placed")
+		})
+
+		t.Run("order does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE orders`).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectPrepare(`SELECT status FROM orders`).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+			orderRepository := NewOrderRepository(db, nil)
+			err = orderRepository.TransitionStatus(context.Background(), expectedOrderID, models.OrderStatusPlaced, models.OrderStatusPaid)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestOrder_StrictValidation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	orderRepository := NewOrderRepository(db, nil, WithStrictValidation())
+	orderEntity := &models.Order{
+		CustomerID: 1,
+		Amount:     models.Money{Value: models.MustDecimalFromInt(10), Currency: models.USD},
+		Items: []*models.OrderItem{
+			{
+				ProductID: 1,
+				Quantity:  2,
+				Price:     models.Money{Value: models.MustDecimalFromInt(4), Currency: models.USD},
+			},
+		},
+	}
+
+	err = orderRepository.Save(context.Background(), orderEntity)
+	require.True(t, errors.Is(err, repositories.ErrValidation))
+	require.True(t, errors.Is(err, models.ErrInvalidOrder))
+
+	err = orderRepository.Update(context.Background(), orderEntity)
+	require.True(t, errors.Is(err, repositories.ErrValidation))
+	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,181 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+const productColumns = "product_id, sku, name, description, price, currency, active, created_at, updated_at"
+
+func NewProductRepository(db *sql.DB, options ...Option) repositories.ProductRepository {
+	return &product{
+		settings: newSettings(options),
+		db:       db,
+		stmts:    newStatements(db),
+	}
+}
+
+type product struct {
+	settings
+	db    *sql.DB
+	stmts *statements
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner) (*models.Product, error) {
+	product := &models.Product{}
+	err := row.Scan(&product.ID, &product.SKU, &product.Name, &product.Description,
+		&product.Price.Value, &product.Price.Currency, &product.Active, &product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	utc(&product.CreatedAt, &product.UpdatedAt)
+	return product, nil
+}
+
+func (p *product) GetByID(ctx context.Context, productID models.ProductID) (*models.Product, error) {
+	stmt, err := p.stmts.prepare(ctx, "SELECT "+productColumns+" FROM products WHERE product_id=$1")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	product, err := scanProduct(stmt.QueryRowContext(ctx, productID))
+	if err != nil {
+		return nil, errors.Wrapf(mapError(err), "product %d", productID)
+	}
+
+	return product, nil
+}
+
+func (p *product) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	stmt, err := p.stmts.prepare(ctx, "SELECT "+productColumns+" FROM products WHERE sku=$1")
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	product, err := scanProduct(stmt.QueryRowContext(ctx, sku))
+	if err != nil {
+		return nil, errors.Wrapf(mapError(err), "product %q", sku)
+	}
+
+	return product, nil
+}
+
+func (p *product) GetByIDs(ctx context.Context, productIDs []models.ProductID) ([]*models.Product, error) {
+	if len(productIDs) == 0 {
+		return []*models.Product{}, nil
+	}
+
+	ids := make(pq.Int64Array, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	found, err := p.query(ctx, "SELECT "+productColumns+" FROM products WHERE product_id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[models.ProductID]*models.Product, len(found))
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+
+	products := make([]*models.Product, 0, len(found))
+	for _, id := range productIDs {
+		if product, ok := byID[id]; ok {
+			products = append(products, product)
+			delete(byID, id)
+		}
+	}
+
+	return products, nil
+}
+
+func (p *product) List(ctx context.Context, activeOnly bool, limit, offset int) ([]*models.Product, error) {
+	query := "SELECT " + productColumns + " FROM products ORDER BY product_id LIMIT $1 OFFSET $2"
+	if activeOnly {
+		query = "SELECT " + productColumns + " FROM products WHERE active ORDER BY product_id LIMIT $1 OFFSET $2"
+	}
+
+	return p.query(ctx, query, limit, offset)
+}
+
+func (p *product) query(ctx context.Context, query string, args ...interface{}) ([]*models.Product, error) {
+	stmt, err := p.stmts.prepare(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, errors.Wrap(mapError(err), "query error")
+	}
+	defer rows.Close()
+
+	products := []*models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, errors.Wrap(mapError(err), "scan error")
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(mapError(err), "rows error")
+	}
+
+	return products, nil
+}
+
+func (p *product) Save(ctx context.Context, product *models.Product) error {
+	if err := product.Price.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate price error")
+	}
+
+	stmt, err := p.stmts.prepare(ctx, "INSERT INTO products (sku, name, description, price, currency, active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7) RETURNING product_id")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	now := p.now()
+	row := stmt.QueryRowContext(ctx, product.SKU, product.Name, product.Description, product.Price.Value, product.Price.Currency, product.Active, now)
+	if err := row.Scan(&product.ID); err != nil {
+		return errors.Wrap(mapError(err), "query row error")
+	}
+	product.CreatedAt, product.UpdatedAt = now, now
+
+	return nil
+}
+
+func (p *product) UpdatePrice(ctx context.Context, productID models.ProductID, price models.Money) error {
+	if err := price.Validate(); err != nil {
+		return errors.Wrap(validationError(err), "validate price error")
+	}
+
+	stmt, err := p.stmts.prepare(ctx, "UPDATE products SET price=$2, currency=$3, updated_at=$4 WHERE product_id=$1")
+	if err != nil {
+		return errors.Wrap(mapError(err), "prepare query error")
+	}
+
+	result, err := stmt.ExecContext(ctx, productID, price.Value, price.Currency, p.now())
+	if err != nil {
+		return errors.Wrap(mapError(err), "exec error")
+	}
+
+	return errors.Wrapf(mapError(expectAffected(result)), "product %d", productID)
+}
+
+func (p *product) Close() error {
+	return p.stmts.Close()
+}
@@ -0,0 +1,247 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+var productRowColumns = []string{"product_id", "sku", "name", "description", "price", "currency", "active", "created_at", "updated_at"}
+
+func TestProduct_GetByID(t *testing.T) {
+	expectedProductID := models.ProductID(4)
+	createdAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT product_id, sku, name, description, price, currency, active, created_at, updated_at FROM products WHERE product_id=\$1`).
+			ExpectQuery().
+			WithArgs(expectedProductID).
+			WillReturnRows(sqlmock.NewRows(productRowColumns).
+				AddRow(expectedProductID, "SKU-4", "Pen", "Blue pen", "1.2500", "usd", true, createdAt, createdAt))
+
+		productRepository := NewProductRepository(db)
+		product, err := productRepository.GetByID(context.Background(), expectedProductID)
+		require.NoError(t, err)
+		require.Equal(t, &models.Product{
+			ID:          expectedProductID,
+			SKU:         "SKU-4",
+			Name:        "Pen",
+			Description: "Blue pen",
+			Price:       models.Money{Value: models.MustParseDecimal("1.25"), Currency: models.USD},
+			Active:      true,
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+		}, product)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("product does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT (.+) FROM products`).ExpectQuery().
+				WillReturnRows(sqlmock.NewRows(productRowColumns))
+
+			productRepository := NewProductRepository(db)
+			product, err := productRepository.GetByID(context.Background(), expectedProductID)
+			require.Nil(t, product)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+	})
+}
+
+func TestProduct_GetBySKU(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare(`SELECT (.+) FROM products WHERE sku=\$1`).
+		ExpectQuery().
+		WithArgs("SKU-7").
+		WillReturnRows(sqlmock.NewRows(productRowColumns).
+			AddRow(7, "SKU-7", "Pencil", "", "0.5000", "eur", false, time.Time{}, time.Time{}))
+
+	productRepository := NewProductRepository(db)
+	product, err := productRepository.GetBySKU(context.Background(), "SKU-7")
+	require.NoError(t, err)
+	require.Equal(t, models.ProductID(7), product.ID)
+	require.False(t, product.Active)
+}
+
+func TestProduct_GetByIDs(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT (.+) FROM products WHERE product_id = ANY\(\$1\)`).
+			ExpectQuery().
+			WithArgs("{3,1,2}").
+			WillReturnRows(sqlmock.NewRows(productRowColumns).
+				AddRow(1, "SKU-1", "Pen", "", "1.0000", "usd", true, time.Time{}, time.Time{}).
+				AddRow(3, "SKU-3", "Ink", "", "3.0000", "usd", true, time.Time{}, time.Time{}))
+
+		productRepository := NewProductRepository(db)
+		products, err := productRepository.GetByIDs(context.Background(), []models.ProductID{3, 1, 2})
+		require.NoError(t, err)
+		require.Len(t, products, 2)
+		require.Equal(t, models.ProductID(3), products[0].ID)
+		require.Equal(t, models.ProductID(1), products[1].ID)
+	})
+
+	t.Run("no ids", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		productRepository := NewProductRepository(db)
+		products, err := productRepository.GetByIDs(context.Background(), nil)
+		require.NoError(t, err)
+		require.Empty(t, products)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestProduct_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare(`SELECT (.+) FROM products WHERE active ORDER BY product_id LIMIT \$1 OFFSET \$2`).
+		ExpectQuery().
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows(productRowColumns).
+			AddRow(21, "SKU-21", "Pen", "", "1.0000", "usd", true, time.Time{}, time.Time{}))
+
+	productRepository := NewProductRepository(db)
+	products, err := productRepository.List(context.Background(), true, 10, 20)
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	require.Equal(t, models.ProductID(21), products[0].ID)
+}
+
+func TestProduct_Save(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		// the clock runs in another zone and with nanoseconds the database does not keep
+		clock := repositories.ClockFunc(func() time.Time {
+			return time.Date(2019, 3, 1, 13, 0, 0, 123456789, time.FixedZone("MSK", 3*60*60))
+		})
+		now := time.Date(2019, 3, 1, 10, 0, 0, 123456000, time.UTC)
+		mock.ExpectPrepare(`INSERT INTO products \(sku, name, description, price, currency, active, created_at, updated_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$7\) RETURNING product_id`).
+			ExpectQuery().
+			WithArgs("SKU-5", "Pen", "Blue pen", "2.5000", "usd", true, now).
+			WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(5))
+
+		product := &models.Product{
+			SKU:         "SKU-5",
+			Name:        "Pen",
+			Description: "Blue pen",
+			Price:       models.Money{Value: models.MustParseDecimal("2.5"), Currency: models.USD},
+			Active:      true,
+		}
+		productRepository := NewProductRepository(db, WithClock(clock))
+		err = productRepository.Save(context.Background(), product)
+		require.NoError(t, err)
+		require.Equal(t, models.ProductID(5), product.ID)
+		require.Equal(t, now, product.CreatedAt)
+		require.Equal(t, now, product.UpdatedAt)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("unknown currency", func(t *testing.T) {
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			productRepository := NewProductRepository(db)
+			err = productRepository.Save(context.Background(), &models.Product{Price: models.Money{Currency: "zzz"}})
+			require.True(t, errors.Is(err, repositories.ErrValidation))
+		})
+	})
+}
+
+func TestProduct_UpdatePrice(t *testing.T) {
+	expectedProductID := models.ProductID(4)
+	price := models.Money{Value: models.MustParseDecimal("3.75"), Currency: models.EUR}
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`UPDATE products SET price=\$2, currency=\$3, updated_at=\$4 WHERE product_id=\$1`).
+			ExpectExec().
+			WithArgs(expectedProductID, "3.7500", "eur", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		productRepository := NewProductRepository(db)
+		err = productRepository.UpdatePrice(context.Background(), expectedProductID, price)
+		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dummyError := errors.New("dummy-error")
+
+		t.Run("product does not exist", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE products`).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+
+			productRepository := NewProductRepository(db)
+			err = productRepository.UpdatePrice(context.Background(), expectedProductID, price)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+		})
+
+		t.Run("exec returns an error", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`UPDATE products`).ExpectExec().WillReturnError(dummyError)
+
+			productRepository := NewProductRepository(db)
+			err = productRepository.UpdatePrice(context.Background(), expectedProductID, price)
+			require.Equal(t, errors.Cause(err), dummyError)
+		})
+	})
+}
@@ -0,0 +1,76 @@
+package postgresql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// RetryPolicy controls how a transaction aborted by a serialization failure
+// or a deadlock is run again. The delay before attempt n+1 is a random
+// duration up to BaseDelay*2^(n-1), capped by MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts limits the number of runs, zero or one disables retries
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// OnRetry is called after a failed attempt before waiting for the next one
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy is used by transaction managers and by repositories for
+// the transactions they start themselves unless WithRetryPolicy replaces it
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// retryable reports whether a transaction failed because of concurrent
+// transactions and may succeed when run again
+func retryable(err error) bool {
+	return errors.Is(mapError(err), repositories.ErrSerialization)
+}
+
+// run calls fn until it succeeds, fails with an error which is not retryable
+// or runs out of attempts. The last error is returned when ctx is done while waiting.
+func (p RetryPolicy) run(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a jittered delay after the attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
@@ -0,0 +1,226 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, limit := range map[int]time.Duration{
+		1:  10 * time.Millisecond,
+		2:  20 * time.Millisecond,
+		3:  40 * time.Millisecond,
+		4:  50 * time.Millisecond,
+		64: 50 * time.Millisecond,
+	} {
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(attempt)
+			require.True(t, delay >= 0 && delay <= limit, "attempt %d: %s", attempt, delay)
+		}
+	}
+
+	require.Zero(t, RetryPolicy{}.backoff(3))
+}
+
+func TestTxManager_Retry(t *testing.T) {
+	ctx := context.Background()
+	serializationFailure := &pq.Error{Code: "40001", Message: "could not serialize access"}
+	deadlock := &pq.Error{Code: "40P01", Message: "deadlock detected"}
+
+	type retry struct {
+		attempt int
+		err     error
+	}
+	newPolicy := func(retries *[]retry) RetryPolicy {
+		return RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    time.Millisecond,
+			OnRetry: func(attempt int, delay time.Duration, err error) {
+				*retries = append(*retries, retry{attempt, err})
+			},
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		t.Run("commit fails once", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectCommit().WillReturnError(serializationFailure)
+			mock.ExpectBegin()
+			mock.ExpectCommit()
+
+			var retries []retry
+			var calls int
+			err = NewTxManager(db, WithRetryPolicy(newPolicy(&retries))).WithinTransaction(ctx, func(ctx context.Context) error {
+				calls++
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 2, calls)
+			require.Len(t, retries, 1)
+			require.Equal(t, 1, retries[0].attempt)
+			require.True(t, errors.Is(retries[0].err, repositories.ErrSerialization))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("order save is retried", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`INSERT INTO orders`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO orders`).WillReturnError(deadlock)
+			mock.ExpectRollback()
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO orders`).
+				WillReturnRows(sqlmock.NewRows([]string{"order_id", "version"}).AddRow(7, 1))
+			mock.ExpectCommit()
+
+			var retries []retry
+			orderRepository := NewOrderRepository(db, nil, WithRetryPolicy(newPolicy(&retries)))
+			orderEntity := &models.Order{CustomerID: 1, Amount: models.Money{Currency: models.USD}}
+			require.NoError(t, orderRepository.Save(ctx, orderEntity))
+			require.Equal(t, models.OrderID(7), orderEntity.ID)
+			require.Len(t, retries, 1)
+			require.True(t, errors.Is(retries[0].err, deadlock))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("nested transaction retries the outer one", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`UPDATE customers`).WillReturnError(serializationFailure)
+			mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+			mock.ExpectBegin()
+			mock.ExpectExec(`SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`UPDATE customers`).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`RELEASE SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+
+			var retries []retry
+			txManager := NewTxManager(db, WithRetryPolicy(newPolicy(&retries)))
+			err = txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+				return txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+					_, err := txFromContext(ctx, db).tx.ExecContext(ctx, "UPDATE customers SET balance=0")
+					return err
+				})
+			})
+			require.NoError(t, err)
+			require.Len(t, retries, 1)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("attempts are exhausted", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			for i := 0; i < 3; i++ {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			}
+
+			var retries []retry
+			err = NewTxManager(db, WithRetryPolicy(newPolicy(&retries))).WithinTransaction(ctx, func(ctx context.Context) error {
+				return deadlock
+			})
+			require.True(t, errors.Is(err, deadlock))
+			require.Equal(t, []retry{{1, deadlock}, {2, deadlock}}, retries)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("repository policy disables retries", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectPrepare(`SELECT balance, currency FROM customers`)
+			mock.ExpectPrepare(`UPDATE customers`)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT balance, currency FROM customers`).WillReturnError(serializationFailure)
+			mock.ExpectRollback()
+
+			customerRepository := NewCustomerRepository(db, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+			_, err = customerRepository.AdjustBalance(ctx, 1, models.Money{Value: models.MustDecimalFromInt(10), Currency: models.USD})
+			require.True(t, errors.Is(err, repositories.ErrSerialization))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("error is not retryable", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			var retries []retry
+			uniqueViolation := &pq.Error{Code: "23505"}
+			err = NewTxManager(db, WithRetryPolicy(newPolicy(&retries))).WithinTransaction(ctx, func(ctx context.Context) error {
+				return uniqueViolation
+			})
+			require.Equal(t, uniqueViolation, errors.Cause(err))
+			require.Empty(t, retries)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("context is done while waiting", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			ctx, cancel := context.WithCancel(ctx)
+			policy := RetryPolicy{
+				MaxAttempts: 3,
+				BaseDelay:   time.Hour,
+				OnRetry: func(attempt int, delay time.Duration, err error) {
+					cancel()
+				},
+			}
+			err = NewTxManager(db, WithRetryPolicy(policy)).WithinTransaction(ctx, func(ctx context.Context) error {
+				return serializationFailure
+			})
+			require.True(t, errors.Is(err, serializationFailure))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package postgresql
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// Option configures a repository of any kind, it is an OrderOption as well
+type Option func(*settings)
+
+// settings are common to all repositories
+type settings struct {
+	clock       repositories.Clock
+	idGenerator repositories.IDGenerator
+	retryPolicy RetryPolicy
+}
+
+// WithClock makes a repository stamp entities with the time of clock
+// instead of repositories.SystemClock
+func WithClock(clock repositories.Clock) Option {
+	return func(s *settings) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator makes a repository set public IDs of new entities with generator
+// instead of repositories.SerialIDGenerator. Only orders and customers have
+// public IDs, product and order item repositories ignore it.
+func WithIDGenerator(generator repositories.IDGenerator) Option {
+	return func(s *settings) {
+		s.idGenerator = generator
+	}
+}
+
+// WithRetryPolicy makes a repository or a transaction manager run transactions
+// which it starts itself with policy instead of DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(s *settings) {
+		s.retryPolicy = policy
+	}
+}
+
+func newSettings(options []Option) settings {
+	s := settings{
+		clock:       repositories.SystemClock,
+		idGenerator: repositories.SerialIDGenerator,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+// now returns the time of the clock in UTC with the microsecond precision of
+// the database, so a stored timestamp reads back equal to the one set on the entity
+func (s settings) now() time.Time {
+	return s.clock.Now().UTC().Truncate(time.Microsecond)
+}
+
+// newPublicID returns the public ID the caller assigned or generates one,
+// which is NULL when it is left to the serial key. An assigned serial
+// public ID is rejected as it would take the one of a later insert.
+func (s settings) newPublicID(assigned string) (sql.NullString, error) {
+	if repositories.IsSerialPublicID(assigned) {
+		return sql.NullString{}, validationError(errors.Errorf("public id %q is reserved for serial keys", assigned))
+	}
+	if assigned != "" {
+		return sql.NullString{String: assigned, Valid: true}, nil
+	}
+	id, err := s.idGenerator.NewID()
+	if err != nil {
+		return sql.NullString{}, errors.Wrap(err, "generate public id error")
+	}
+	return sql.NullString{String: id, Valid: id != ""}, nil
+}
+
+// publicID is the public ID of an entity inserted with the generated one and the serial key
+func publicID(generated sql.NullString, serial int) string {
+	if generated.Valid {
+		return generated.String
+	}
+	return strconv.Itoa(serial)
+}
+
+// utc converts scanned timestamps, which come in the session time zone, to UTC
+func utc(times ...*time.Time) {
+	for _, t := range times {
+		*t = t.UTC()
+	}
+}
+
+// utcNullable is utc for a timestamp which may be NULL
+func utcNullable(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,109 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// statements prepares every query once per *sql.DB and keeps the statement
+// until Close, database/sql re-prepares it on other connections of the pool
+type statements struct {
+	db     *sql.DB
+	mu     sync.Mutex
+	cache  map[string]*sql.Stmt
+	closed bool
+}
+
+func newStatements(db *sql.DB) *statements {
+	return &statements{
+		db:    db,
+		cache: map[string]*sql.Stmt{},
+	}
+}
+
+// prepare returns the cached statement of the query, preparing it on first use.
+// Within a transaction carried by ctx the statement is bound to the transaction,
+// a query not cached yet is prepared on the transaction and not cached: preparing
+// it on the pool while the transaction holds a connection could wait forever
+// for a free connection in a bounded pool.
+// The statement is owned by the cache and must not be closed by the caller.
+func (s *statements) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	stmt, ok := s.cache[query]
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, repositories.ErrClosed
+	}
+	if state := txFromContext(ctx, s.db); state != nil {
+		return state.prepare(ctx, query, stmt)
+	}
+	if ok {
+		return stmt, nil
+	}
+
+	// the lock is not held while preparing, so a concurrent call may win
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		stmt.Close()
+		return nil, repositories.ErrClosed
+	}
+	if cached, ok := s.cache[query]; ok {
+		stmt.Close()
+		return cached, nil
+	}
+	s.cache[query] = stmt
+
+	return stmt, nil
+}
+
+// check fails with repositories.ErrClosed after Close, it guards queries
+// which are run without a prepared statement
+func (s *statements) check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return repositories.ErrClosed
+	}
+	return nil
+}
+
+// warm caches statements of the queries before a transaction takes
+// a connection, it does nothing when ctx already carries a transaction
+func (s *statements) warm(ctx context.Context, queries ...string) error {
+	if txFromContext(ctx, s.db) != nil {
+		return nil
+	}
+	for _, query := range queries {
+		if _, err := s.prepare(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all cached statements, later calls fail with repositories.ErrClosed
+func (s *statements) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range s.cache {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.cache, query)
+	}
+	s.closed = true
+
+	return firstErr
+}
@@ -0,0 +1,129 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+	"time"
+)
+
+var orderItemListColumns = []string{"order_item_id", "order_id", "product_id", "quantity", "price", "currency", "created_at", "updated_at", "deleted_at"}
+
+func TestStatements(t *testing.T) {
+	t.Run("prepared once", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT (.+) FROM order_items WHERE order_id=\$1`)
+		for i := 0; i < 3; i++ {
+			mock.ExpectQuery(`SELECT (.+) FROM order_items WHERE order_id=\$1`).
+				WillReturnRows(sqlmock.NewRows(orderItemListColumns).AddRow(1, 1, 2, 1, "1.0000", "usd", time.Now(), time.Now(), nil))
+		}
+
+		orderItemRepository := NewOrderItemRepository(db)
+		for i := 0; i < 3; i++ {
+			_, err := orderItemRepository.GetByOrderID(context.Background(), 1)
+			require.NoError(t, err)
+		}
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cached statement is reused in a transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`INSERT INTO order_items`)
+		mock.ExpectQuery(`INSERT INTO order_items`).WillReturnRows(sqlmock.NewRows([]string{"order_item_id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO order_items`).WillReturnRows(sqlmock.NewRows([]string{"order_item_id"}).AddRow(2))
+		mock.ExpectCommit()
+
+		ctx := context.Background()
+		orderItemRepository := NewOrderItemRepository(db)
+		orderItem := func() *models.OrderItem {
+			return &models.OrderItem{OrderID: 1, ProductID: 2, Quantity: 1, Price: models.Money{Currency: models.USD}}
+		}
+		require.NoError(t, orderItemRepository.Save(ctx, orderItem()))
+
+		err = NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+			return orderItemRepository.Save(ctx, orderItem())
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("close", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPrepare(`SELECT (.+) FROM orders WHERE order_id=\$1`).WillBeClosed().
+			ExpectQuery().
+			WillReturnRows(sqlmock.NewRows(orderListColumns))
+
+		orderRepository := NewOrderRepository(db, nil)
+		_, err = orderRepository.GetByID(context.Background(), 1)
+		require.True(t, errors.Is(err, repositories.ErrNotFound))
+
+		require.NoError(t, orderRepository.Close())
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		_, err = orderRepository.GetByID(context.Background(), 1)
+		require.True(t, errors.Is(err, repositories.ErrClosed))
+		require.NoError(t, orderRepository.Close())
+	})
+
+	t.Run("no leaks with a bounded pool", func(t *testing.T) {
+		const calls = 3000
+
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+
+		// every third call fails while scanning rows, rows left open would keep
+		// the only connection busy and block the next call
+		mock.ExpectPrepare(`SELECT (.+) FROM order_items WHERE order_id=\$1`)
+		for i := 0; i < calls; i++ {
+			price := "1.0000"
+			if i%3 == 0 {
+				price = "not-a-number"
+			}
+			mock.ExpectQuery(`SELECT (.+) FROM order_items WHERE order_id=\$1`).
+				WillReturnRows(sqlmock.NewRows(orderItemListColumns).
+					AddRow(1, 1, 2, 1, price, "usd", time.Now(), time.Now(), nil).
+					AddRow(2, 1, 3, 1, "1.0000", "usd", time.Now(), time.Now(), nil))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		orderItemRepository := NewOrderItemRepository(db)
+		for i := 0; i < calls; i++ {
+			_, err := orderItemRepository.GetByOrderID(ctx, 1)
+			require.NotEqual(t, context.DeadlineExceeded, errors.Cause(err), "call %d", i)
+		}
+
+		require.NoError(t, orderItemRepository.Close())
+		require.NoError(t, mock.ExpectationsWereMet())
+		stats := db.Stats()
+		require.Equal(t, 0, stats.InUse)
+		require.Equal(t, 1, stats.OpenConnections)
+	})
+}
@@ -0,0 +1,199 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// TxManagerOption configures a transaction manager, every Option is a TxManagerOption
+type TxManagerOption interface {
+	applyTxManager(*txManager)
+}
+
+func (f Option) applyTxManager(m *txManager) {
+	f(&m.settings)
+}
+
+// NewTxManager returns a manager which runs fn again, in a new transaction,
+// when the transaction fails with repositories.ErrSerialization. fn must not
+// have side effects outside of the database and should build the entities
+// it stores itself, as IDs set by a failed attempt are not reset.
+func NewTxManager(db *sql.DB, options ...TxManagerOption) repositories.TxManager {
+	m := &txManager{
+		db:       db,
+		settings: newSettings(nil),
+	}
+	for _, option := range options {
+		option.applyTxManager(m)
+	}
+	return m
+}
+
+type txManager struct {
+	settings
+	db *sql.DB
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error, options ...repositories.TxOption) error {
+	return transact(ctx, m.db, repositories.NewTxOptions(options...), m.retryPolicy, fn)
+}
+
+// txKey is the context key of the transaction state
+type txKey struct{}
+
+// txState is a transaction carried by a context. A transaction is not meant
+// for concurrent use, but statements and savepoints are guarded anyway.
+type txState struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	options repositories.TxOptions
+
+	mu         sync.Mutex
+	savepoints int
+	stmts      map[string]*sql.Stmt
+}
+
+// txFromContext returns the transaction on db carried by ctx or nil,
+// a transaction on another *sql.DB is ignored
+func txFromContext(ctx context.Context, db *sql.DB) *txState {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok || state.db != db {
+		return nil
+	}
+	return state
+}
+
+// transact runs fn in a new transaction, retried according to the policy,
+// or, when ctx already carries one, in a savepoint of it. Only the outermost
+// transaction is retried, as a failure aborts the transaction as a whole.
+// Repositories run multi-statement operations with it, so an operation failing
+// within a caller's transaction leaves no partial changes.
+func transact(ctx context.Context, db *sql.DB, options repositories.TxOptions, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	if state := txFromContext(ctx, db); state != nil {
+		return state.savepoint(ctx, options, fn)
+	}
+
+	return policy.run(ctx, func() error {
+		return runTx(ctx, db, options, fn)
+	})
+}
+
+// runTx runs fn in a new transaction, committed when fn succeeds
+func runTx(ctx context.Context, db *sql.DB, options repositories.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly})
+	if err != nil {
+		return errors.Wrap(mapError(err), "begin transaction error")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	state := &txState{
+		db:      db,
+		tx:      tx,
+		options: options,
+		stmts:   map[string]*sql.Stmt{},
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		return rollback(tx, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(mapError(err), "commit error")
+	}
+
+	return nil
+}
+
+// savepoint runs fn in a savepoint of the transaction
+func (s *txState) savepoint(ctx context.Context, options repositories.TxOptions, fn func(ctx context.Context) error) error {
+	if options != (repositories.TxOptions{}) && options != s.options {
+		return errors.Errorf("options %+v of a nested transaction differ from %+v of the outer one", options, s.options)
+	}
+
+	s.mu.Lock()
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+	s.mu.Unlock()
+
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return errors.Wrap(mapError(err), "savepoint error")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		if _, e := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); e != nil {
+			return errors.Wrap(err, e.Error())
+		}
+		return err
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return errors.Wrap(mapError(err), "release savepoint error")
+	}
+
+	return nil
+}
+
+// prepare returns a statement of the query bound to the transaction, it is
+// prepared once per transaction and closed when the transaction ends.
+// A statement cached on the pool is reused instead of being prepared again.
+func (s *txState) prepare(ctx context.Context, query string, cached *sql.Stmt) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	var stmt *sql.Stmt
+	if cached != nil {
+		stmt = s.tx.StmtContext(ctx, cached)
+	} else {
+		var err error
+		if stmt, err = s.tx.PrepareContext(ctx, query); err != nil {
+			return nil, err
+		}
+	}
+	s.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// rollback aborts tx and returns err. A transaction already rolled back by
+// a cancelled context is not reported as a second failure.
+func rollback(tx *sql.Tx, err error) error {
+	if e := tx.Rollback(); e != nil && e != sql.ErrTxDone {
+		return errors.Wrap(err, e.Error())
+	}
+	return err
+}
+
+// queryer runs ad hoc queries on the pool or in a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// conn returns the transaction carried by ctx or db itself
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if state := txFromContext(ctx, db); state != nil {
+		return state.tx
+	}
+	return db
+}
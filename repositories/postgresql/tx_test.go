@@ -0,0 +1,199 @@
+// +build unit
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v2"
+	"testing"
+)
+
+func TestTxManager_WithinTransaction(t *testing.T) {
+	ctx := context.Background()
+	dummyError := errors.New("dummy-error")
+
+	t.Run("success", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		// a multi-statement operation runs in a savepoint of the caller's transaction
+		mock.ExpectExec(`SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare(`SELECT balance, currency FROM customers WHERE customer_id=\$1 FOR UPDATE`).
+			ExpectQuery().
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"balance", "currency"}).AddRow("10.0000", "usd"))
+		mock.ExpectPrepare(`UPDATE customers SET balance=\$2, updated_at=\$3 WHERE customer_id=\$1`).
+			ExpectExec().
+			WithArgs(7, "2.0000", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`RELEASE SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectPrepare(`SELECT (.+) FROM products WHERE product_id=\$1`).
+			ExpectQuery().
+			WithArgs(3).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectCommit()
+
+		customerRepository := NewCustomerRepository(db)
+		productRepository := NewProductRepository(db)
+		err = NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+			balance, err := customerRepository.AdjustBalance(ctx, 7, models.Money{Value: models.MustDecimalFromInt(-8), Currency: models.USD})
+			if err != nil {
+				return err
+			}
+			require.Equal(t, "2.0000", balance.Value.String())
+
+			_, err = productRepository.GetByID(ctx, 3)
+			require.True(t, errors.Is(err, repositories.ErrNotFound))
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`RELEASE SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		txManager := NewTxManager(db)
+		err = txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			state := txFromContext(ctx, db)
+			require.Equal(t, repositories.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, state.options)
+
+			// a nested transaction inherits options of the outer one
+			require.NoError(t, txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+				return nil
+			}))
+			return txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+				return nil
+			}, repositories.WithIsolation(sql.LevelReadCommitted))
+		}, repositories.WithIsolation(sql.LevelSerializable), repositories.ReadOnly())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "nested transaction")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SAVEPOINT sp_2`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp_2`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`SAVEPOINT sp_3`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`RELEASE SAVEPOINT sp_3`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		txManager := NewTxManager(db)
+		err = txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			err := txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+				return dummyError
+			})
+			require.Equal(t, dummyError, errors.Cause(err))
+
+			require.Panics(t, func() {
+				txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+					panic("dummy-panic")
+				})
+			})
+
+			return txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Run("function fails", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			err = NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+				return dummyError
+			})
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("function panics", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+
+			require.PanicsWithValue(t, "dummy-panic", func() {
+				NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+					panic("dummy-panic")
+				})
+			})
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("begin fails", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin().WillReturnError(dummyError)
+
+			err = NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+				t.Fatal("function must not be called")
+				return nil
+			})
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+
+		t.Run("commit fails", func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mock.ExpectBegin()
+			mock.ExpectCommit().WillReturnError(dummyError)
+
+			err = NewTxManager(db).WithinTransaction(ctx, func(ctx context.Context) error {
+				return nil
+			})
+			require.Equal(t, errors.Cause(err), dummyError)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	})
+}
@@ -0,0 +1,22 @@
+//go:generate mockgen -source=product.go -package repositories -destination product_mock.go
+
+package repositories
+
+import (
+	"context"
+
+	"github.com/netology/dao-pattern/models"
+)
+
+// ProductRepository is a repository
+type ProductRepository interface {
+	GetByID(ctx context.Context, productID models.ProductID) (*models.Product, error)
+	GetBySKU(ctx context.Context, sku string) (*models.Product, error)
+	// GetByIDs returns found products in the order of productIDs, missing ones are skipped
+	GetByIDs(ctx context.Context, productIDs []models.ProductID) ([]*models.Product, error)
+	List(ctx context.Context, activeOnly bool, limit, offset int) ([]*models.Product, error)
+	Save(ctx context.Context, product *models.Product) error
+	UpdatePrice(ctx context.Context, productID models.ProductID, price models.Money) error
+	// Close releases prepared statements, later calls fail with ErrClosed
+	Close() error
+}
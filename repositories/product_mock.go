@@ -0,0 +1,137 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: product.go
+
+// Package repositories is a generated GoMock package.
+package repositories
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/netology/dao-pattern/models"
+	reflect "reflect"
+)
+
+// MockProductRepository is a mock of ProductRepository interface
+type MockProductRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockProductRepositoryMockRecorder
+}
+
+// MockProductRepositoryMockRecorder is the mock recorder for MockProductRepository
+type MockProductRepositoryMockRecorder struct {
+	mock *MockProductRepository
+}
+
+// NewMockProductRepository creates a new mock instance
+func NewMockProductRepository(ctrl *gomock.Controller) *MockProductRepository {
+	mock := &MockProductRepository{ctrl: ctrl}
+	mock.recorder = &MockProductRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockProductRepository) EXPECT() *MockProductRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetByID mocks base method
+func (m *MockProductRepository) GetByID(ctx context.Context, productID models.ProductID) (*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByID", ctx, productID)
+	ret0, _ := ret[0].(*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByID indicates an expected call of GetByID
+func (mr *MockProductRepositoryMockRecorder) GetByID(ctx, productID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockProductRepository)(nil).GetByID), ctx, productID)
+}
+
+// GetBySKU mocks base method
+func (m *MockProductRepository) GetBySKU(ctx context.Context, sku string) (*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBySKU", ctx, sku)
+	ret0, _ := ret[0].(*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBySKU indicates an expected call of GetBySKU
+func (mr *MockProductRepositoryMockRecorder) GetBySKU(ctx, sku interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBySKU", reflect.TypeOf((*MockProductRepository)(nil).GetBySKU), ctx, sku)
+}
+
+// GetByIDs mocks base method
+func (m *MockProductRepository) GetByIDs(ctx context.Context, productIDs []models.ProductID) ([]*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByIDs", ctx, productIDs)
+	ret0, _ := ret[0].([]*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIDs indicates an expected call of GetByIDs
+func (mr *MockProductRepositoryMockRecorder) GetByIDs(ctx, productIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockProductRepository)(nil).GetByIDs), ctx, productIDs)
+}
+
+// List mocks base method
+func (m *MockProductRepository) List(ctx context.Context, activeOnly bool, limit, offset int) ([]*models.Product, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, activeOnly, limit, offset)
+	ret0, _ := ret[0].([]*models.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockProductRepositoryMockRecorder) List(ctx, activeOnly, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockProductRepository)(nil).List), ctx, activeOnly, limit, offset)
+}
+
+// Save mocks base method
+func (m *MockProductRepository) Save(ctx context.Context, product *models.Product) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, product)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockProductRepositoryMockRecorder) Save(ctx, product interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockProductRepository)(nil).Save), ctx, product)
+}
+
+// UpdatePrice mocks base method
+func (m *MockProductRepository) UpdatePrice(ctx context.Context, productID models.ProductID, price models.Money) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePrice", ctx, productID, price)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePrice indicates an expected call of UpdatePrice
+func (mr *MockProductRepositoryMockRecorder) UpdatePrice(ctx, productID, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePrice", reflect.TypeOf((*MockProductRepository)(nil).UpdatePrice), ctx, productID, price)
+}
+
+// Close mocks base method
+func (m *MockProductRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockProductRepositoryMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockProductRepository)(nil).Close))
+}
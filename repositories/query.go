@@ -0,0 +1,25 @@
+package repositories
+
+// QueryOptions configure a read, the zero value skips soft deleted entities
+type QueryOptions struct {
+	IncludeDeleted bool
+}
+
+// QueryOption configures a read of a repository
+type QueryOption func(*QueryOptions)
+
+// IncludeDeleted makes a read return soft deleted entities as well, e.g. for an audit
+func IncludeDeleted() QueryOption {
+	return func(o *QueryOptions) {
+		o.IncludeDeleted = true
+	}
+}
+
+// NewQueryOptions applies options to the zero QueryOptions
+func NewQueryOptions(options ...QueryOption) QueryOptions {
+	var o QueryOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
@@ -0,0 +1,674 @@
+// Package repositorytest contains contract tests which every implementation
+// of the repositories interfaces is expected to pass.
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
+)
+
+// OrderBackend is an OrderRepository under test together with its
+// transaction manager and entities that stored orders may refer to
+type OrderBackend struct {
+	Orders     repositories.OrderRepository
+	Tx         repositories.TxManager
+	CustomerID models.CustomerID
+	ProductIDs []models.ProductID
+}
+
+// OrderFactory creates a backend for a single test, ProductIDs must hold at least two products
+type OrderFactory func(t *testing.T) OrderBackend
+
+// RunOrderRepositorySuite checks that an OrderRepository stores and loads orders
+// the way the postgresql backend does
+func RunOrderRepositorySuite(t *testing.T, factory OrderFactory) {
+	t.Run("save and load", func(t *testing.T) {
+		testSaveAndLoad(t, factory(t))
+	})
+	t.Run("rollback on item failure", func(t *testing.T) {
+		testRollbackOnItemFailure(t, factory(t))
+	})
+	t.Run("not found", func(t *testing.T) {
+		testNotFound(t, factory(t))
+	})
+	t.Run("money precision", func(t *testing.T) {
+		testMoneyPrecision(t, factory(t))
+	})
+	t.Run("update and delete", func(t *testing.T) {
+		testUpdateAndDelete(t, factory(t))
+	})
+	t.Run("optimistic locking", func(t *testing.T) {
+		testOptimisticLocking(t, factory(t))
+	})
+	t.Run("soft delete", func(t *testing.T) {
+		testSoftDelete(t, factory(t))
+	})
+	t.Run("concurrent saves", func(t *testing.T) {
+		testConcurrentSaves(t, factory(t))
+	})
+	t.Run("status transitions", func(t *testing.T) {
+		testStatusTransitions(t, factory(t))
+	})
+	t.Run("batch load", func(t *testing.T) {
+		testBatchLoad(t, factory(t))
+	})
+	t.Run("public id", func(t *testing.T) {
+		testPublicID(t, factory(t))
+	})
+	t.Run("idempotent save", func(t *testing.T) {
+		testIdempotentSave(t, factory(t))
+	})
+	t.Run("list", func(t *testing.T) {
+		testList(t, factory(t))
+	})
+	t.Run("close", func(t *testing.T) {
+		testClose(t, factory(t))
+	})
+	t.Run("transactions", func(t *testing.T) {
+		testTransactions(t, factory(t))
+	})
+}
+
+func newOrder(backend OrderBackend, prices ...string) *models.Order {
+	order := &models.Order{
+		CustomerID: backend.CustomerID,
+		Amount:     models.Money{Currency: models.USD},
+	}
+	for i, price := range prices {
+		item := &models.OrderItem{
+			ProductID: backend.ProductIDs[i%len(backend.ProductIDs)],
+			Quantity:  1,
+			Price:     models.Money{Value: models.MustParseDecimal(price), Currency: models.USD},
+		}
+		order.Items = append(order.Items, item)
+		order.Amount.Value += item.Price.Value
+	}
+	return order
+}
+
+func testSaveAndLoad(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8", "12")
+	err := backend.Orders.Save(ctx, orderEntity)
+	require.NoError(t, err)
+	require.NotZero(t, orderEntity.ID)
+	require.Equal(t, models.OrderStatusDraft, orderEntity.Status)
+	for _, item := range orderEntity.Items {
+		require.NotZero(t, item.ID)
+		require.Equal(t, orderEntity.ID, item.OrderID)
+	}
+
+	order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, orderEntity, order)
+}
+
+func testRollbackOnItemFailure(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8", "-12")
+	err := backend.Orders.Save(ctx, orderEntity)
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+
+	if orderEntity.ID != 0 {
+		_, err = backend.Orders.GetByID(ctx, orderEntity.ID)
+		require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+	}
+}
+
+func testNotFound(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+	missingID := models.OrderID(math.MaxInt32)
+
+	order, err := backend.Orders.GetByID(ctx, missingID)
+	require.Nil(t, order)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	orderEntity := newOrder(backend, "1")
+	orderEntity.ID = missingID
+	err = backend.Orders.Update(ctx, orderEntity)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	err = backend.Orders.Delete(ctx, missingID, 1)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+}
+
+func testMoneyPrecision(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "0.0001", "0.1", "0.2", "9999999999.9999")
+	require.Equal(t, "10000000000.3000", orderEntity.Amount.Value.String())
+
+	err := backend.Orders.Save(ctx, orderEntity)
+	require.NoError(t, err)
+
+	order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, orderEntity.Amount, order.Amount)
+
+	var total models.Decimal
+	for _, item := range order.Items {
+		total += item.Price.Value
+	}
+	require.Equal(t, order.Amount.Value, total)
+}
+
+func testUpdateAndDelete(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8", "12")
+	require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+
+	orderEntity.Items[1].Quantity = 2
+	orderEntity.Items = append(orderEntity.Items[1:], newOrder(backend, "11").Items...)
+	orderEntity.Amount.Value = models.MustDecimalFromInt(35)
+	err := backend.Orders.Update(ctx, orderEntity)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), orderEntity.Version)
+
+	order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, orderEntity, order)
+
+	err = backend.Orders.Delete(ctx, orderEntity.ID, orderEntity.Version)
+	require.NoError(t, err)
+
+	_, err = backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+}
+
+func testOptimisticLocking(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8")
+	require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+	require.Equal(t, int64(1), orderEntity.Version)
+
+	first, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	second, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+
+	first.Items[0].Quantity = 2
+	first.Amount.Value = models.MustDecimalFromInt(16)
+	require.NoError(t, backend.Orders.Update(ctx, first))
+	require.Equal(t, int64(2), first.Version)
+
+	// the second writer read the order before the first one changed it
+	second.Items[0].Quantity = 3
+	second.Amount.Value = models.MustDecimalFromInt(24)
+	err = backend.Orders.Update(ctx, second)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+	require.Equal(t, int64(1), second.Version)
+
+	err = backend.Orders.Delete(ctx, second.ID, second.Version)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+
+	order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, first, order)
+
+	// a status transition is a change of the order as well
+	require.NoError(t, backend.Orders.TransitionStatus(ctx, first.ID, models.OrderStatusDraft, models.OrderStatusPlaced))
+	err = backend.Orders.Update(ctx, first)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+
+	order, err = backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), order.Version)
+	require.NoError(t, backend.Orders.Delete(ctx, order.ID, order.Version))
+}
+
+func testSoftDelete(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	deleted := newOrder(backend, "1", "2")
+	kept := newOrder(backend, "3")
+	require.NoError(t, backend.Orders.Save(ctx, deleted))
+	require.NoError(t, backend.Orders.Save(ctx, kept))
+
+	// only SoftDelete deletes, Update ignores DeletedAt of the order it is given
+	deletedAt := time.Now()
+	kept.DeletedAt = &deletedAt
+	require.NoError(t, backend.Orders.Update(ctx, kept))
+	kept.DeletedAt = nil
+	updated, err := backend.Orders.GetByID(ctx, kept.ID)
+	require.NoError(t, err)
+	require.Nil(t, updated.DeletedAt)
+	require.Len(t, updated.Items, 1)
+	require.Nil(t, updated.Items[0].DeletedAt)
+
+	err = backend.Orders.SoftDelete(ctx, deleted.ID, 2)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+	require.NoError(t, backend.Orders.SoftDelete(ctx, deleted.ID, deleted.Version))
+
+	_, err = backend.Orders.GetByID(ctx, deleted.ID)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	orders, err := backend.Orders.GetByIDs(ctx, []models.OrderID{deleted.ID, kept.ID})
+	require.NoError(t, err)
+	require.Equal(t, []*models.Order{kept}, orders)
+
+	filter := repositories.OrderFilter{CustomerID: backend.CustomerID}
+	page, err := backend.Orders.List(ctx, filter, repositories.Page{})
+	require.NoError(t, err)
+	require.Equal(t, []*models.Order{kept}, page.Orders)
+
+	page, err = backend.Orders.List(ctx, filter, repositories.Page{}, repositories.IncludeDeleted())
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 2)
+
+	// auditors see the order with its items, all of them deleted at once
+	order, err := backend.Orders.GetByID(ctx, deleted.ID, repositories.IncludeDeleted())
+	require.NoError(t, err)
+	require.NotNil(t, order.DeletedAt)
+	require.Equal(t, int64(2), order.Version)
+	require.Len(t, order.Items, 2)
+	for _, item := range order.Items {
+		require.Equal(t, order.DeletedAt, item.DeletedAt)
+	}
+
+	// a deleted order is missing for writes
+	err = backend.Orders.SoftDelete(ctx, deleted.ID, order.Version)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+	err = backend.Orders.Update(ctx, order)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+	err = backend.Orders.TransitionStatus(ctx, deleted.ID, models.OrderStatusDraft, models.OrderStatusPlaced)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+	err = backend.Orders.Restore(ctx, kept.ID, kept.Version)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	err = backend.Orders.Restore(ctx, deleted.ID, deleted.Version)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+	require.NoError(t, backend.Orders.Restore(ctx, deleted.ID, order.Version))
+
+	order, err = backend.Orders.GetByID(ctx, deleted.ID)
+	require.NoError(t, err)
+	require.Nil(t, order.DeletedAt)
+	require.Equal(t, int64(3), order.Version)
+	require.Len(t, order.Items, 2)
+	require.Nil(t, order.Items[0].DeletedAt)
+}
+
+func testConcurrentSaves(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	const n = 20
+	orders := make([]*models.Order, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range orders {
+		orders[i] = newOrder(backend, "1", "2")
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = backend.Orders.Save(ctx, orders[i])
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[models.OrderID]bool{}
+	for i, orderEntity := range orders {
+		require.NoError(t, errs[i])
+		require.False(t, seen[orderEntity.ID], "order id %d is reused", orderEntity.ID)
+		seen[orderEntity.ID] = true
+
+		order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, orderEntity, order)
+	}
+}
+
+func testStatusTransitions(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8")
+	require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+
+	err := backend.Orders.TransitionStatus(ctx, orderEntity.ID, models.OrderStatusDraft, models.OrderStatusPlaced)
+	require.NoError(t, err)
+
+	err = backend.Orders.TransitionStatus(ctx, orderEntity.ID, models.OrderStatusDraft, models.OrderStatusCancelled)
+	require.True(t, errors.Is(err, repositories.ErrStaleStatus), "%v", err)
+
+	err = backend.Orders.TransitionStatus(ctx, orderEntity.ID, models.OrderStatusPlaced, models.OrderStatusDelivered)
+	require.True(t, errors.Is(err, models.ErrIllegalTransition), "%v", err)
+
+	// a transition bumps the version, so the order read before it is stale
+	err = backend.Orders.Update(ctx, orderEntity)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion), "%v", err)
+
+	order, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	order.Status = models.OrderStatusDraft
+	require.NoError(t, backend.Orders.Update(ctx, order))
+
+	order, err = backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.OrderStatusPlaced, order.Status)
+
+	err = backend.Orders.TransitionStatus(ctx, models.OrderID(math.MaxInt32), models.OrderStatusDraft, models.OrderStatusPlaced)
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	// a new order starts as a draft, it may not skip the transitions
+	delivered := newOrder(backend, "8")
+	delivered.Status = models.OrderStatusDelivered
+	err = backend.Orders.Save(ctx, delivered)
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+	require.True(t, errors.Is(err, models.ErrIllegalTransition), "%v", err)
+	require.Zero(t, delivered.ID)
+
+	err = backend.Orders.SaveIdempotent(ctx, fmt.Sprintf("checkout-%d-6", backend.CustomerID), delivered)
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+}
+
+func testBatchLoad(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	first := newOrder(backend, "1", "2")
+	second := newOrder(backend, "3")
+	require.NoError(t, backend.Orders.Save(ctx, first))
+	require.NoError(t, backend.Orders.Save(ctx, second))
+
+	missingID := models.OrderID(math.MaxInt32)
+	orders, err := backend.Orders.GetByIDs(ctx, []models.OrderID{second.ID, missingID, first.ID, second.ID})
+	require.NoError(t, err)
+	require.Equal(t, []*models.Order{second, first}, orders)
+
+	orders, err = backend.Orders.GetByIDs(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, orders)
+}
+
+func testPublicID(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	// backends generate serial public IDs by default
+	orderEntity := newOrder(backend, "1")
+	require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+	require.Equal(t, strconv.Itoa(int(orderEntity.ID)), orderEntity.PublicID)
+
+	order, err := backend.Orders.GetByPublicID(ctx, orderEntity.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, orderEntity, order)
+
+	_, err = backend.Orders.GetByPublicID(ctx, "missing")
+	require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+
+	// a public ID the caller assigns is known before insert and kept as is,
+	// it is unique to the customer as orders outlive a test run in a shared database
+	assigned := newOrder(backend, "1")
+	assigned.PublicID = fmt.Sprintf("order-%d-1", backend.CustomerID)
+	require.NoError(t, backend.Orders.Save(ctx, assigned))
+	require.Equal(t, fmt.Sprintf("order-%d-1", backend.CustomerID), assigned.PublicID)
+
+	order, err = backend.Orders.GetByPublicID(ctx, assigned.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, assigned.ID, order.ID)
+
+	idempotent := newOrder(backend, "1")
+	idempotent.PublicID = fmt.Sprintf("order-%d-2", backend.CustomerID)
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, fmt.Sprintf("checkout-%d-3", backend.CustomerID), idempotent))
+	require.Equal(t, fmt.Sprintf("order-%d-2", backend.CustomerID), idempotent.PublicID)
+
+	order, err = backend.Orders.GetByPublicID(ctx, idempotent.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, idempotent.ID, order.ID)
+
+	generated := newOrder(backend, "1")
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, fmt.Sprintf("checkout-%d-4", backend.CustomerID), generated))
+	require.Equal(t, strconv.Itoa(int(generated.ID)), generated.PublicID)
+
+	// serial public IDs are reserved, an assigned one would take the public ID of a later order
+	reserved := newOrder(backend, "1")
+	reserved.PublicID = strconv.Itoa(int(generated.ID) + 1)
+	err = backend.Orders.Save(ctx, reserved)
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+	err = backend.Orders.SaveIdempotent(ctx, fmt.Sprintf("checkout-%d-5", backend.CustomerID), reserved)
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+
+	next := newOrder(backend, "1")
+	require.NoError(t, backend.Orders.Save(ctx, next))
+	require.Equal(t, strconv.Itoa(int(next.ID)), next.PublicID)
+
+	// Update keeps the stored public ID of an order which comes without one
+	update := newOrder(backend, "2")
+	update.ID, update.Version = orderEntity.ID, orderEntity.Version
+	require.NoError(t, backend.Orders.Update(ctx, update))
+
+	order, err = backend.Orders.GetByPublicID(ctx, orderEntity.PublicID)
+	require.NoError(t, err)
+	require.Equal(t, orderEntity.ID, order.ID)
+	require.Equal(t, orderEntity.PublicID, order.PublicID)
+}
+
+func testIdempotentSave(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+	// keys outlive a test run in a shared database, so they are unique to the customer
+	key := fmt.Sprintf("checkout-%d-1", backend.CustomerID)
+	otherKey := fmt.Sprintf("checkout-%d-2", backend.CustomerID)
+
+	first := newOrder(backend, "1", "2")
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, key, first))
+
+	// a retry gets the order created first instead of a duplicate
+	retry := newOrder(backend, "1", "2")
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, key, retry))
+	require.Equal(t, first, retry)
+
+	page, err := backend.Orders.List(ctx, repositories.OrderFilter{CustomerID: backend.CustomerID}, repositories.Page{})
+	require.NoError(t, err)
+	require.Len(t, page.Orders, 1)
+
+	err = backend.Orders.SaveIdempotent(ctx, key, newOrder(backend, "3"))
+	require.True(t, errors.Is(err, repositories.ErrIdempotencyKeyReused), "%v", err)
+
+	other := newOrder(backend, "1", "2")
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, otherKey, other))
+	require.NotEqual(t, first.ID, other.ID)
+
+	// a deleted order releases its key
+	require.NoError(t, backend.Orders.Delete(ctx, first.ID, first.Version))
+	again := newOrder(backend, "1", "2")
+	require.NoError(t, backend.Orders.SaveIdempotent(ctx, key, again))
+	require.NotEqual(t, first.ID, again.ID)
+}
+
+func testList(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	prices := []string{"5", "1", "4", "2", "3"}
+	for _, price := range prices {
+		require.NoError(t, backend.Orders.Save(ctx, newOrder(backend, price)))
+	}
+	filter := repositories.OrderFilter{CustomerID: backend.CustomerID}
+
+	for _, sort := range []repositories.OrderSort{"", repositories.SortByAmountAsc, repositories.SortByAmountDesc} {
+		var orders []*models.Order
+		page := repositories.Page{Limit: 2, Sort: sort}
+		for {
+			result, err := backend.Orders.List(ctx, filter, page)
+			require.NoError(t, err)
+			require.True(t, len(result.Orders) <= page.Limit)
+			orders = append(orders, result.Orders...)
+			if result.NextCursor == "" {
+				break
+			}
+			page.Cursor = result.NextCursor
+		}
+
+		require.Len(t, orders, len(prices), "sort %q", sort)
+		seen := map[models.OrderID]bool{}
+		for i, order := range orders {
+			require.False(t, seen[order.ID], "order %d listed twice", order.ID)
+			seen[order.ID] = true
+			require.Len(t, order.Items, 1)
+			if i == 0 {
+				continue
+			}
+			switch sort {
+			case repositories.SortByAmountAsc:
+				require.True(t, orders[i-1].Amount.Value < order.Amount.Value)
+			case repositories.SortByAmountDesc:
+				require.True(t, orders[i-1].Amount.Value > order.Amount.Value)
+			default:
+				require.False(t, orders[i-1].CreatedAt.Before(order.CreatedAt))
+			}
+		}
+	}
+
+	result, err := backend.Orders.List(ctx, filter, repositories.Page{Sort: repositories.SortByAmountAsc})
+	require.NoError(t, err)
+	require.NoError(t, backend.Orders.TransitionStatus(ctx, result.Orders[4].ID, models.OrderStatusDraft, models.OrderStatusPlaced))
+
+	minAmount := models.MustParseDecimal("2")
+	result, err = backend.Orders.List(ctx, repositories.OrderFilter{
+		CustomerID: backend.CustomerID,
+		MinAmount:  &minAmount,
+		Statuses:   []models.OrderStatus{models.OrderStatusDraft},
+	}, repositories.Page{Sort: repositories.SortByAmountAsc})
+	require.NoError(t, err)
+	require.Len(t, result.Orders, 3)
+	require.Equal(t, models.MustParseDecimal("2"), result.Orders[0].Amount.Value)
+	require.Empty(t, result.NextCursor)
+
+	_, err = backend.Orders.List(ctx, filter, repositories.Page{Sort: "price"})
+	require.True(t, errors.Is(err, repositories.ErrValidation), "%v", err)
+}
+
+func testClose(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+
+	orderEntity := newOrder(backend, "8")
+	require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+	require.NoError(t, backend.Orders.Close())
+
+	_, err := backend.Orders.GetByID(ctx, orderEntity.ID)
+	require.True(t, errors.Is(err, repositories.ErrClosed), "%v", err)
+
+	err = backend.Orders.Save(ctx, newOrder(backend, "8"))
+	require.True(t, errors.Is(err, repositories.ErrClosed), "%v", err)
+
+	_, err = backend.Orders.List(ctx, repositories.OrderFilter{CustomerID: backend.CustomerID}, repositories.Page{})
+	require.True(t, errors.Is(err, repositories.ErrClosed), "%v", err)
+}
+
+func testTransactions(t *testing.T, backend OrderBackend) {
+	ctx := context.Background()
+	dummyError := errors.New("dummy-error")
+
+	requireNotFound := func(orderID models.OrderID) {
+		_, err := backend.Orders.GetByID(ctx, orderID)
+		require.True(t, errors.Is(err, repositories.ErrNotFound), "%v", err)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		first, second := newOrder(backend, "8"), newOrder(backend, "12")
+		err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := backend.Orders.Save(ctx, first); err != nil {
+				return err
+			}
+			// changes are visible within the transaction
+			if _, err := backend.Orders.GetByID(ctx, first.ID); err != nil {
+				return err
+			}
+			return backend.Orders.Save(ctx, second)
+		})
+		require.NoError(t, err)
+
+		orders, err := backend.Orders.GetByIDs(ctx, []models.OrderID{first.ID, second.ID})
+		require.NoError(t, err)
+		require.Equal(t, []*models.Order{first, second}, orders)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		orderEntity := newOrder(backend, "8")
+		err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := backend.Orders.Save(ctx, orderEntity); err != nil {
+				return err
+			}
+			return dummyError
+		})
+		require.Equal(t, dummyError, errors.Cause(err))
+		requireNotFound(orderEntity.ID)
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		orderEntity := newOrder(backend, "8")
+		require.Panics(t, func() {
+			backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				if err := backend.Orders.Save(ctx, orderEntity); err != nil {
+					return err
+				}
+				panic("dummy-panic")
+			})
+		})
+		requireNotFound(orderEntity.ID)
+	})
+
+	t.Run("nested transaction rolls back to a savepoint", func(t *testing.T) {
+		outer, inner := newOrder(backend, "8"), newOrder(backend, "12")
+		err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := backend.Orders.Save(ctx, outer); err != nil {
+				return err
+			}
+
+			err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				if err := backend.Orders.Save(ctx, inner); err != nil {
+					return err
+				}
+				return dummyError
+			})
+			if errors.Cause(err) != dummyError {
+				return errors.Errorf("unexpected error of the nested transaction: %v", err)
+			}
+
+			_, err = backend.Orders.GetByID(ctx, inner.ID)
+			if !errors.Is(err, repositories.ErrNotFound) {
+				return errors.Errorf("order of the nested transaction is visible: %v", err)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		order, err := backend.Orders.GetByID(ctx, outer.ID)
+		require.NoError(t, err)
+		require.Equal(t, outer, order)
+		requireNotFound(inner.ID)
+	})
+
+	t.Run("nested transaction with other options", func(t *testing.T) {
+		err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			return backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+				return nil
+			}, repositories.ReadOnly())
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		orderEntity := newOrder(backend, "8")
+		require.NoError(t, backend.Orders.Save(ctx, orderEntity))
+
+		err := backend.Tx.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, err := backend.Orders.GetByID(ctx, orderEntity.ID); err != nil {
+				return err
+			}
+			return backend.Orders.Save(ctx, newOrder(backend, "12"))
+		}, repositories.ReadOnly(), repositories.WithIsolation(sql.LevelRepeatableRead))
+		require.True(t, errors.Is(err, repositories.ErrReadOnly), "%v", err)
+	})
+}
@@ -0,0 +1,52 @@
+//go:generate mockgen -source=tx.go -package repositories -destination tx_mock.go
+
+package repositories
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TxManager runs functions in a transaction spanning all repositories
+// of the same storage. The transaction is carried by the context passed to fn,
+// repository calls made with that context take part in it.
+type TxManager interface {
+	// WithinTransaction commits when fn returns nil and rolls back when it
+	// returns an error or panics, the panic is propagated. A call with a context
+	// already carrying a transaction runs fn in a savepoint of it: only the
+	// changes of fn are rolled back on failure and options must either be
+	// omitted or match the outer transaction.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error, options ...TxOption) error
+}
+
+// TxOptions configure a transaction, the zero value uses storage defaults
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+// TxOption configures a transaction started by TxManager
+type TxOption func(*TxOptions)
+
+// WithIsolation sets the isolation level of a transaction
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *TxOptions) {
+		o.Isolation = level
+	}
+}
+
+// ReadOnly makes writes within a transaction fail with ErrReadOnly
+func ReadOnly() TxOption {
+	return func(o *TxOptions) {
+		o.ReadOnly = true
+	}
+}
+
+// NewTxOptions applies options to the zero TxOptions
+func NewTxOptions(options ...TxOption) TxOptions {
+	var o TxOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
@@ -3,42 +3,119 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	_ "github.com/lib/pq"
+	"github.com/netology/dao-pattern/migrations"
 	"github.com/netology/dao-pattern/models"
+	"github.com/netology/dao-pattern/repositories"
 	"github.com/netology/dao-pattern/repositories/postgresql"
+	"github.com/netology/dao-pattern/repositories/repositorytest"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"math"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
+func openDB(t *testing.T) *sql.DB {
+	cfg, err := postgresql.ConfigFromEnv()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := postgresql.Open(ctx, cfg)
+	require.NoError(t, err)
+
+	_, err = newMigrator(t, db).Up(ctx)
+	require.NoError(t, err)
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB) *migrations.Migrator {
+	scripts, err := migrations.Load(migrations.Scripts())
+	require.NoError(t, err)
+	return migrations.NewMigrator(db, scripts)
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	defer db.Close()
+
+	migrator := newMigrator(t, db)
+	require.NoError(t, migrator.Validate(ctx))
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		require.Equal(t, migrations.StateApplied, status.State, status.Script)
+	}
+
+	count, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	count, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+// seed creates a customer and three products referenced by orders
+func seed(t *testing.T, db *sql.DB) (models.CustomerID, []models.ProductID) {
+	ctx := context.Background()
+
+	customer := &models.Customer{Balance: models.Money{Currency: models.USD}}
+	require.NoError(t, postgresql.NewCustomerRepository(db).Save(ctx, customer))
+
+	productRepository := postgresql.NewProductRepository(db)
+	productIDs := make([]models.ProductID, 3)
+	for i := range productIDs {
+		product := &models.Product{
+			SKU:   fmt.Sprintf("SKU-%d-%d", time.Now().UnixNano(), i),
+			Price: models.Money{Currency: models.USD},
+		}
+		require.NoError(t, productRepository.Save(ctx, product))
+		productIDs[i] = product.ID
+	}
+
+	return customer.ID, productIDs
+}
+
 func TestIntegration(t *testing.T) {
-	db := postgresql.NewConnection()
+	ctx := context.Background()
+	db := openDB(t)
 	defer db.Close()
 
+	customerID, productIDs := seed(t, db)
+
 	t.Run("success commint", func(t *testing.T) {
 		orderItemRepository := postgresql.NewOrderItemRepository(db)
 		orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
 		orderEntity := &models.Order{
-			CustomerID: 1,
-			Amount:     models.Money{20, models.USD},
+			CustomerID: customerID,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(20), Currency: models.USD},
 			Items: []*models.OrderItem{
 				{
-					ProductID: 1,
+					ProductID: productIDs[0],
 					Quantity:  1,
-					Price:     models.Money{8, models.USD},
+					Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
 				},
 				{
-					ProductID: 2,
+					ProductID: productIDs[1],
 					Quantity:  26,
-					Price:     models.Money{12, models.USD},
+					Price:     models.Money{Value: models.MustDecimalFromInt(12), Currency: models.USD},
 				},
 			},
 		}
-		err := orderRepository.Save(orderEntity)
+		err := orderRepository.Save(ctx, orderEntity)
 		require.NoError(t, err)
 
-		order, err := orderRepository.GetByID(orderEntity.ID)
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
 		require.NoError(t, err)
 		require.NotNil(t, order)
 		require.Len(t, order.Items, 2)
@@ -48,28 +125,413 @@ func TestIntegration(t *testing.T) {
 		orderItemRepository := postgresql.NewOrderItemRepository(db)
 		orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
 		orderEntity := &models.Order{
-			CustomerID: 1,
-			Amount:     models.Money{20, models.USD},
+			CustomerID: customerID,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(20), Currency: models.USD},
 			Items: []*models.OrderItem{
 				{
-					ProductID: 1,
+					ProductID: productIDs[0],
 					Quantity:  1,
-					Price:     models.Money{-8, models.USD},
+					Price:     models.Money{Value: models.MustDecimalFromInt(-8), Currency: models.USD},
 				},
 				{
-					ProductID: 2,
+					ProductID: productIDs[1],
 					Quantity:  26,
-					Price:     models.Money{12, models.USD},
+					Price:     models.Money{Value: models.MustDecimalFromInt(12), Currency: models.USD},
 				},
 			},
 		}
-		err := orderRepository.Save(orderEntity)
+		err := orderRepository.Save(ctx, orderEntity)
 		require.Error(t, err)
+		require.True(t, errors.Is(err, repositories.ErrValidation))
 
-		order, err := orderRepository.GetByID(orderEntity.ID)
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
 		require.Error(t, err)
-		require.Equal(t, errors.Cause(err), sql.ErrNoRows)
+		require.True(t, errors.Is(err, repositories.ErrNotFound))
 		require.Nil(t, order)
 	})
 
+	t.Run("update and delete", func(t *testing.T) {
+		orderItemRepository := postgresql.NewOrderItemRepository(db)
+		orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
+		orderEntity := &models.Order{
+			CustomerID: customerID,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(20), Currency: models.USD},
+			Items: []*models.OrderItem{
+				{
+					ProductID: productIDs[0],
+					Quantity:  1,
+					Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+				},
+				{
+					ProductID: productIDs[1],
+					Quantity:  1,
+					Price:     models.Money{Value: models.MustDecimalFromInt(12), Currency: models.USD},
+				},
+			},
+		}
+		err := orderRepository.Save(ctx, orderEntity)
+		require.NoError(t, err)
+
+		orderEntity.Amount.Value = models.MustDecimalFromInt(35)
+		orderEntity.Items[1].Quantity = 2
+		orderEntity.Items = append(orderEntity.Items[1:], &models.OrderItem{
+			ProductID: productIDs[2],
+			Quantity:  1,
+			Price:     models.Money{Value: models.MustDecimalFromInt(11), Currency: models.USD},
+		})
+		err = orderRepository.Update(ctx, orderEntity)
+		require.NoError(t, err)
+
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, orderEntity.Amount, order.Amount)
+		require.Len(t, order.Items, 2)
+		require.Equal(t, int64(2), order.Version)
+
+		err = orderRepository.Delete(ctx, orderEntity.ID, 1)
+		require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+
+		err = orderRepository.Delete(ctx, orderEntity.ID, order.Version)
+		require.NoError(t, err)
+
+		_, err = orderRepository.GetByID(ctx, orderEntity.ID)
+		require.True(t, errors.Is(err, repositories.ErrNotFound))
+	})
+
+	t.Run("missing customer", func(t *testing.T) {
+		orderItemRepository := postgresql.NewOrderItemRepository(db)
+		orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
+		orderEntity := &models.Order{
+			CustomerID: -1,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+			Items: []*models.OrderItem{
+				{
+					ProductID: productIDs[0],
+					Quantity:  1,
+					Price:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+				},
+			},
+		}
+		err := orderRepository.Save(ctx, orderEntity)
+		require.True(t, errors.Is(err, repositories.ErrInvalidReference))
+
+		var repositoryError *repositories.Error
+		require.True(t, errors.As(err, &repositoryError))
+		require.Equal(t, "customer", repositoryError.Entity)
+		require.Equal(t, "-1", repositoryError.Key)
+	})
+
+	t.Run("bulk insert of items", func(t *testing.T) {
+		orderItemRepository := postgresql.NewOrderItemRepository(db)
+		orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
+
+		orderEntity := &models.Order{CustomerID: customerID, Amount: models.Money{Currency: models.USD}}
+		for i := 0; i < 500; i++ {
+			orderEntity.Items = append(orderEntity.Items, &models.OrderItem{
+				ProductID: productIDs[i%len(productIDs)],
+				Quantity:  1,
+				Price:     models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
+			})
+		}
+		require.NoError(t, orderEntity.RecalculateAmount())
+		require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+		order, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, orderEntity.Items, order.Items)
+
+		orderEntity = &models.Order{CustomerID: customerID, Amount: models.Money{Currency: models.USD}}
+		for i := 0; i <= postgresql.DefaultBulkInsertThreshold; i++ {
+			orderEntity.Items = append(orderEntity.Items, &models.OrderItem{
+				ProductID: productIDs[0],
+				Quantity:  1,
+				Price:     models.Money{Currency: models.USD},
+			})
+		}
+		orderEntity.Items[7].ProductID = -1
+		err = orderRepository.Save(ctx, orderEntity)
+		require.True(t, errors.Is(err, repositories.ErrInvalidReference), "%v", err)
+		require.Zero(t, orderEntity.Items[0].ID)
+
+		_, err = orderRepository.GetByID(ctx, orderEntity.ID)
+		require.True(t, errors.Is(err, repositories.ErrNotFound))
+	})
+}
+
+func TestOrderRepositorySuite(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	repositorytest.RunOrderRepositorySuite(t, func(t *testing.T) repositorytest.OrderBackend {
+		customerID, productIDs := seed(t, db)
+		return repositorytest.OrderBackend{
+			Orders:     postgresql.NewOrderRepository(db, postgresql.NewOrderItemRepository(db)),
+			Tx:         postgresql.NewTxManager(db),
+			CustomerID: customerID,
+			ProductIDs: productIDs,
+		}
+	})
+}
+
+func TestCustomerIntegration(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	defer db.Close()
+
+	customerRepository := postgresql.NewCustomerRepository(db)
+
+	t.Run("save, adjust and list", func(t *testing.T) {
+		customerEntity := &models.Customer{
+			Balance: models.Money{Value: models.MustParseDecimal("10.25"), Currency: models.USD},
+		}
+		err := customerRepository.Save(ctx, customerEntity)
+		require.NoError(t, err)
+
+		balance, err := customerRepository.AdjustBalance(ctx, customerEntity.ID, models.Money{Value: models.MustParseDecimal("-0.05"), Currency: models.USD})
+		require.NoError(t, err)
+		require.Equal(t, "10.2000", balance.Value.String())
+
+		customer, err := customerRepository.GetByID(ctx, customerEntity.ID)
+		require.NoError(t, err)
+		require.Equal(t, balance, customer.Balance)
+
+		customers, err := customerRepository.List(ctx, 1000, 0)
+		require.NoError(t, err)
+		require.NotEmpty(t, customers)
+	})
+
+	t.Run("balance can not become negative", func(t *testing.T) {
+		customerEntity := &models.Customer{
+			Balance: models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD},
+		}
+		err := customerRepository.Save(ctx, customerEntity)
+		require.NoError(t, err)
+
+		_, err = customerRepository.AdjustBalance(ctx, customerEntity.ID, models.Money{Value: models.MustDecimalFromInt(-2), Currency: models.USD})
+		require.True(t, errors.Is(err, repositories.ErrValidation))
+	})
+
+	t.Run("generated public id", func(t *testing.T) {
+		customerRepository := postgresql.NewCustomerRepository(db, postgresql.WithIDGenerator(repositories.NewUUIDv7Generator(repositories.SystemClock)))
+		customerEntity := &models.Customer{Balance: models.Money{Currency: models.USD}}
+		require.NoError(t, customerRepository.Save(ctx, customerEntity))
+		require.Len(t, customerEntity.PublicID, 36)
+
+		customer, err := customerRepository.GetByPublicID(ctx, customerEntity.PublicID)
+		require.NoError(t, err)
+		require.Equal(t, customerEntity.ID, customer.ID)
+	})
+}
+
+func TestTxManagerIntegration(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+	// repositories must not wait for a second connection within a transaction
+	db.SetMaxOpenConns(1)
+
+	customerID, productIDs := seed(t, db)
+	txManager := postgresql.NewTxManager(db)
+	orderRepository := postgresql.NewOrderRepository(db, postgresql.NewOrderItemRepository(db))
+	customerRepository := postgresql.NewCustomerRepository(db)
+	productRepository := postgresql.NewProductRepository(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := customerRepository.AdjustBalance(ctx, customerID, models.Money{Value: models.MustDecimalFromInt(100), Currency: models.USD})
+	require.NoError(t, err)
+
+	// placeOrder charges the customer and stores the order in one transaction
+	placeOrder := func(productID models.ProductID) (*models.Order, error) {
+		orderEntity := &models.Order{
+			CustomerID: customerID,
+			Amount:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+			Items: []*models.OrderItem{
+				{ProductID: productID, Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD}},
+			},
+		}
+		err := txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			if _, err := productRepository.GetByID(ctx, productIDs[0]); err != nil {
+				return err
+			}
+			charge := models.Money{Value: models.MustDecimalFromInt(-8), Currency: models.USD}
+			if _, err := customerRepository.AdjustBalance(ctx, customerID, charge); err != nil {
+				return err
+			}
+			return orderRepository.Save(ctx, orderEntity)
+		}, repositories.WithIsolation(sql.LevelSerializable))
+		return orderEntity, err
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		orderEntity, err := placeOrder(productIDs[0])
+		require.NoError(t, err)
+
+		customer, err := customerRepository.GetByID(ctx, customerID)
+		require.NoError(t, err)
+		require.Equal(t, "92.0000", customer.Balance.Value.String())
+
+		_, err = orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		// the order refers to a missing product after the customer was charged
+		_, err := placeOrder(models.ProductID(math.MaxInt32))
+		require.True(t, errors.Is(err, repositories.ErrInvalidReference), "%v", err)
+
+		customer, err := customerRepository.GetByID(ctx, customerID)
+		require.NoError(t, err)
+		require.Equal(t, "92.0000", customer.Balance.Value.String())
+	})
+
+	require.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestRetryIntegration(t *testing.T) {
+	const (
+		workers    = 8
+		increments = 5
+	)
+
+	ctx := context.Background()
+	db := openDB(t)
+	defer db.Close()
+
+	customerID, _ := seed(t, db)
+	customerRepository := postgresql.NewCustomerRepository(db)
+
+	var retries int32
+	policy := postgresql.DefaultRetryPolicy
+	policy.MaxAttempts = 50
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		atomic.AddInt32(&retries, 1)
+	}
+	txManager := postgresql.NewTxManager(db, postgresql.WithRetryPolicy(policy))
+
+	// read-modify-write without locks conflicts under SERIALIZABLE
+	increment := func() error {
+		return txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			customer, err := customerRepository.GetByID(ctx, customerID)
+			if err != nil {
+				return err
+			}
+			customer.Balance, err = customer.Balance.Add(models.Money{Value: models.MustDecimalFromInt(1), Currency: models.USD})
+			if err != nil {
+				return err
+			}
+			return customerRepository.Update(ctx, customer)
+		}, repositories.WithIsolation(sql.LevelSerializable))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*increments)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				errs <- increment()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	customer, err := customerRepository.GetByID(ctx, customerID)
+	require.NoError(t, err)
+	require.Equal(t, models.MustDecimalFromInt(workers*increments).String(), customer.Balance.Value.String())
+	t.Logf("%d retries", atomic.LoadInt32(&retries))
+}
+
+func TestProductIntegration(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	defer db.Close()
+
+	productRepository := postgresql.NewProductRepository(db)
+
+	t.Run("save, reprice and load", func(t *testing.T) {
+		sku := fmt.Sprintf("SKU-%d", time.Now().UnixNano())
+		productEntity := &models.Product{
+			SKU:    sku,
+			Name:   "Pen",
+			Price:  models.Money{Value: models.MustParseDecimal("1.99"), Currency: models.USD},
+			Active: true,
+		}
+		err := productRepository.Save(ctx, productEntity)
+		require.NoError(t, err)
+		require.False(t, productEntity.CreatedAt.IsZero())
+
+		price := models.Money{Value: models.MustParseDecimal("2.49"), Currency: models.USD}
+		err = productRepository.UpdatePrice(ctx, productEntity.ID, price)
+		require.NoError(t, err)
+
+		product, err := productRepository.GetBySKU(ctx, sku)
+		require.NoError(t, err)
+		require.Equal(t, productEntity.ID, product.ID)
+		require.Equal(t, price, product.Price)
+
+		products, err := productRepository.GetByIDs(ctx, []models.ProductID{productEntity.ID})
+		require.NoError(t, err)
+		require.Len(t, products, 1)
+	})
+
+	t.Run("sku is unique", func(t *testing.T) {
+		sku := fmt.Sprintf("SKU-%d", time.Now().UnixNano())
+		productEntity := &models.Product{SKU: sku, Price: models.Money{Currency: models.USD}}
+		err := productRepository.Save(ctx, productEntity)
+		require.NoError(t, err)
+
+		err = productRepository.Save(ctx, &models.Product{SKU: sku, Price: models.Money{Currency: models.USD}})
+		require.True(t, errors.Is(err, repositories.ErrConflict))
+	})
+}
+
+func TestStatementCacheIntegration(t *testing.T) {
+	const calls = 2000
+
+	db := openDB(t)
+	defer db.Close()
+	// with a single connection any leaked rows or statement block the next call
+	db.SetMaxOpenConns(1)
+
+	customerID, productIDs := seed(t, db)
+	orderItemRepository := postgresql.NewOrderItemRepository(db)
+	orderRepository := postgresql.NewOrderRepository(db, orderItemRepository)
+	customerRepository := postgresql.NewCustomerRepository(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	orderEntity := &models.Order{
+		CustomerID: customerID,
+		Amount:     models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD},
+		Items: []*models.OrderItem{
+			{ProductID: productIDs[0], Quantity: 1, Price: models.Money{Value: models.MustDecimalFromInt(8), Currency: models.USD}},
+		},
+	}
+	require.NoError(t, orderRepository.Save(ctx, orderEntity))
+
+	for i := 0; i < calls; i++ {
+		_, err := orderRepository.GetByID(ctx, orderEntity.ID)
+		require.NoError(t, err, "call %d", i)
+
+		_, err = orderRepository.GetByID(ctx, models.OrderID(math.MaxInt32))
+		require.True(t, errors.Is(err, repositories.ErrNotFound), "call %d: %v", i, err)
+
+		_, err = customerRepository.AdjustBalance(ctx, customerID, models.Money{Currency: models.USD})
+		require.NoError(t, err, "call %d", i)
+	}
+
+	require.NoError(t, orderRepository.Close())
+	require.NoError(t, orderItemRepository.Close())
+	require.NoError(t, customerRepository.Close())
+	require.Equal(t, 0, db.Stats().InUse)
+
+	_, err := orderRepository.GetByID(ctx, orderEntity.ID)
+	require.True(t, errors.Is(err, repositories.ErrClosed), "%v", err)
 }